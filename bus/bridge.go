@@ -0,0 +1,167 @@
+package bus
+
+import (
+	"distributed-sys-emulator/log"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+/* The bridge exposes the bus over http, so tools outside of the emulator, e.g.
+* a python notebook or a dashboard, can follow a simulation and control it :
+* - GET /topics lists the event types
+* - GET /events streams events as server-sent events, all of them or only those
+*   of the types given by ?type=node-output&type=sent-to
+* - POST /publish publishes the event in the body, {"type": "...", "data": ...}
+*
+* Events are encoded as json like in a recording. A client that does not keep
+* up loses events instead of holding up the bus.
+*
+* Any web page open in a browser may send requests to the bridge. Publishing is
+* therefore limited to the events controlling a run, so a remote client can not
+* e.g. change the code, which runs on the host. Requests sent by a browser on
+* behalf of a page carry an Origin header and are rejected, as are bodies that
+* are not json, which a page could send without the browser asking the bridge.
+ */
+
+// events buffered per client before they are dropped
+const bridgeBuffer = 256
+
+// event types that may be published over the bridge
+var remoteTopics = map[EventType]bool{
+	StartNodesEvt:    true,
+	StopNodesEvt:     true,
+	ConnectNodesEvt:  true,
+	ContinueNodesEvt: true,
+}
+
+type Bridge struct {
+	eb  EventBus
+	mux *http.ServeMux
+}
+
+func NewBridge(eb EventBus) *Bridge {
+	b := &Bridge{eb: eb, mux: http.NewServeMux()}
+	b.mux.HandleFunc("/topics", b.topics)
+	b.mux.HandleFunc("/events", b.events)
+	b.mux.HandleFunc("/publish", b.publish)
+	return b
+}
+
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+func (b *Bridge) topics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	etypes := []string{}
+	eachTopic(func(etype EventType, _ reflect.Type) {
+		etypes = append(etypes, string(etype))
+	})
+	sort.Strings(etypes)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(etypes)
+}
+
+func (b *Bridge) events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	etypes := make(map[EventType]bool)
+	for _, etype := range r.URL.Query()["type"] {
+		if _, ok := topicType(EventType(etype)); !ok {
+			http.Error(w, "unknown event type "+etype, http.StatusBadRequest)
+			return
+		}
+		etypes[EventType(etype)] = true
+	}
+	requested := func(e Event) bool {
+		return len(etypes) == 0 || etypes[e.Type]
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := make(chan Event, bridgeBuffer)
+	s, _ := b.eb.AwaitBindWildcard("*", func(e Event) {
+		select {
+		case events <- e:
+		default:
+			log.Debug("Bridge client too slow, dropped event ", e.Type)
+		}
+	}, Where(requested))
+	defer s.Unsubscribe()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-events:
+			raw, err := json.Marshal(e)
+			if err != nil {
+				log.Error(err, " bridging event ", e.Type)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, raw); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (b *Bridge) publish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("Origin") != "" {
+		http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "content type has to be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var body struct {
+		Type EventType       `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := topicType(body.Type); ok && !remoteTopics[body.Type] {
+		http.Error(w, "event type may not be published remotely", http.StatusForbidden)
+		return
+	}
+	e, err := decodeEvent(body.Type, body.Data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !b.eb.AwaitPublish(e) {
+		http.Error(w, "event data type does not match", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
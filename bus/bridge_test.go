@@ -0,0 +1,182 @@
+package bus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// a stand-in client reading the events streamed by the bridge
+func streamEvents(t *testing.T, url string) chan Event {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the stream to start, got %s", res.Status)
+	}
+
+	events := make(chan Event, 10)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(data), &e); err == nil {
+				events <- e
+			}
+		}
+		res.Body.Close()
+		close(events)
+	}()
+	return events
+}
+
+func TestBridge_Events(t *testing.T) {
+	eb := NewEventbus()
+	NodeCntChangeTopic.AwaitPublish(eb, 3)
+	server := httptest.NewServer(NewBridge(eb))
+	// closed after the stream, which Close waits for
+	t.Cleanup(server.Close)
+
+	events := streamEvents(t, server.URL+"/events?type=node-output&type=node-count-change")
+
+	// the most recent event is sent on subscribing
+	if e := <-events; e.Type != NodeCntChangeEvt || e.Data != 3.0 {
+		t.Errorf("Expected the recent node count, got %v", e)
+	}
+
+	SentToTopic.AwaitPublish(eb, SendTask{From: 0, To: 1})
+	NodeOutputTopic.AwaitPublish(eb, NodeOutput{NodeId: 2, Result: "a"})
+	e := <-events
+	if e.Type != NodeOutputEvt {
+		t.Fatalf("Expected only the requested types, got %v", e)
+	}
+	if out := e.Data.(map[string]any); out["NodeId"] != 2.0 || out["Result"] != "a" {
+		t.Errorf("Expected the output of node 2, got %v", out)
+	}
+
+	res, err := http.Get(server.URL + "/events?type=unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an unknown type to be rejected, got %s", res.Status)
+	}
+}
+
+func TestBridge_Publish(t *testing.T) {
+	eb := NewEventbus()
+	server := httptest.NewServer(NewBridge(eb))
+	defer server.Close()
+
+	connected := make(chan Connection, 1)
+	ConnectNodesTopic.AwaitSubscribe(eb, func(c Connection) {
+		connected <- c
+	})
+	started := make(chan any, 1)
+	StartNodesTopic.AwaitSubscribe(eb, func() {
+		started <- nil
+	})
+
+	post := func(body string) int {
+		res, err := http.Post(server.URL+"/publish", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	if status := post(`{"type": "connect-nodes", "data": {"From": 1, "To": 2}}`); status != http.StatusNoContent {
+		t.Errorf("Expected the publish to succeed, got %d", status)
+	}
+	if c := <-connected; c.From != 1 || c.To != 2 {
+		t.Errorf("Expected nodes 1 and 2 to be connected, got %v", c)
+	}
+	if status := post(`{"type": "start-nodes"}`); status != http.StatusNoContent {
+		t.Errorf("Expected the publish to succeed, got %d", status)
+	}
+	<-started
+
+	if status := post(`{"type": "unknown"}`); status != http.StatusBadRequest {
+		t.Errorf("Expected an unknown type to be rejected, got %d", status)
+	}
+	if status := post(`{"type": "connect-nodes", "data": "nodes"}`); status != http.StatusBadRequest {
+		t.Errorf("Expected mismatching data to be rejected, got %d", status)
+	}
+	if status := post(`{"type": "code-change", "data": "package main"}`); status != http.StatusForbidden {
+		t.Errorf("Expected an event not controlling the run to be rejected, got %d", status)
+	}
+}
+
+func TestBridge_Publish_From_Browser(t *testing.T) {
+	eb := NewEventbus()
+	server := httptest.NewServer(NewBridge(eb))
+	defer server.Close()
+
+	post := func(contentType, origin string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/publish", strings.NewReader(`{"type": "start-nodes"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	// a page may post plain text to any address without the browser asking
+	if status := post("text/plain", ""); status != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected a body that is not json to be rejected, got %d", status)
+	}
+	if status := post("application/json", "http://example.com"); status != http.StatusForbidden {
+		t.Errorf("Expected a cross-origin request to be rejected, got %d", status)
+	}
+	if status := post("application/json; charset=utf-8", ""); status != http.StatusNoContent {
+		t.Errorf("Expected the publish to succeed, got %d", status)
+	}
+}
+
+func TestBridge_Topics(t *testing.T) {
+	server := httptest.NewServer(NewBridge(NewEventbus()))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/topics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var etypes []EventType
+	if err := json.NewDecoder(res.Body).Decode(&etypes); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, etype := range etypes {
+		found = found || etype == NodeOutputEvt
+	}
+	if !found {
+		t.Errorf("Expected the topics to contain %s, got %v", NodeOutputEvt, etypes)
+	}
+}
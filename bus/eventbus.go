@@ -10,6 +10,8 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 /* This eventbus is supposed to serve as a connection between the core and gui.
@@ -18,6 +20,13 @@ import (
 * - no constantly running process
 * - callbacks may publish
 * - implicit eventtype/eventdata matching and checking at runtime
+* - every bind returns a subscription, which ends exactly that bind
+* - the events of a type are delivered in the order they were published, so a
+*   slow subscriber holds up the type and should take a queue of its own, see
+*   Queue. Awaits that would wait for the delivery they are called from are
+*   detected and logged instead.
+* - interceptors see every event before its delivery, wildcard subscriptions
+*   receive the events of all types or of a prefix, see intercept.go
  */
 
 type EventType string
@@ -28,53 +37,241 @@ type Event struct {
 }
 
 type eventBusData struct {
-	callbacks []reflect.Value // called on event occurence and possibly once when added with the most recent event
-	waitlist  []chan bool     // can be used to await the next occurence of an event
-	recent    *Event
-	cbType    reflect.Type // defines the expected callback signature and publish arg type
+	subscriptions []*Subscription // called on event occurence and possibly once when added with the most recent event
+	waitlist      []*waiter       // can be used to await the next occurence of an event
+	recent        *Event
+	cbType        reflect.Type  // defines the expected callback signature and publish arg type
+	tail          chan struct{} // closed once the last delivery of this type is done, nil if there is none
 }
 
 type EventBus interface {
-	Bind(etype EventType, cb any)
-	AwaitBind(etype EventType, cb any) bool
+	Bind(etype EventType, cb any, opts ...BindOption) *Subscription
+	AwaitBind(etype EventType, cb any, opts ...BindOption) (*Subscription, bool)
+	BindOnce(etype EventType, cb any, opts ...BindOption) *Subscription
 	Publish(e Event)
 	AwaitPublish(e Event) bool
 	Unbind(etype EventType, cb any)
 	AwaitUnbind(etype EventType, cb any) bool
-	AwaitEvent(ctx context.Context, etype EventType)
+	AwaitEvent(ctx context.Context, etype EventType) (any, error)
+	Request(ctx context.Context, e Event, reply EventType, match func(data any) bool) (any, error)
+	BindWildcard(pattern string, cb func(e Event), opts ...BindOption) *Subscription
+	AwaitBindWildcard(pattern string, cb func(e Event), opts ...BindOption) (*Subscription, bool)
+	Intercept(i Interceptor) func()
 }
 
 type eventBus struct {
 	data smap.SMap[EventType, eventBusData]
+
+	// held while an event takes its place in the delivery order, so wildcard
+	// subscriptions are added in between two events
+	mu           sync.Mutex
+	wildcards    []*Subscription
+	interceptors []*Interceptor
+
+	// the callbacks being called by deliveries, to detect awaits that would
+	// wait for their own delivery
+	callingMu sync.Mutex
+	calling   map[*callToken]struct{}
+}
+
+// a callback being called with an event of the type, see eventBus.enter
+type callToken struct {
+	etype EventType
+	name  string
+}
+
+// Subscription is the handle of a single bind
+type Subscription struct {
+	bus    *eventBus
+	etype  EventType
+	cb     reflect.Value
+	name   string        // of the callbacks function, to find it on the stack
+	filter reflect.Value // func(T) bool, the zero Value for none
+	prefix *string       // set for wildcard subscriptions, whose callbacks receive the event
+	once   bool
+	queue  *queue      // nil to be called by the publisher
+	done   atomic.Bool // unsubscribed, or called once
+}
+
+type BindOption func(s *Subscription)
+
+// Once ends the subscription after its first call, which may be the call with
+// the most recent event on bind
+func Once() BindOption {
+	return func(s *Subscription) {
+		s.once = true
+	}
+}
+
+// Where only calls the callback for events whose data the filter accepts. The
+// filter is a func(T) bool for callbacks of type func(T), e.g. to only receive
+// the NodeOutput of a single node.
+func Where(filter any) BindOption {
+	return func(s *Subscription) {
+		s.filter = reflect.ValueOf(filter)
+	}
+}
+
+// Unsubscribe ends exactly this subscription, even if other subscriptions share
+// the callbacks code. Returns false if it already ended.
+func (s *Subscription) Unsubscribe() bool {
+	if !s.done.CompareAndSwap(false, true) {
+		return false
+	}
+	if s.prefix != nil {
+		s.bus.removeWildcard(s)
+	} else {
+		s.bus.remove(s)
+	}
+	if s.queue != nil {
+		s.queue.release()
+	}
+	return true
+}
+
+// passes the data on to the callback or its queue, unless the subscription
+// ended or the filter rejects the data
+func (s *Subscription) deliver(etype EventType, in []reflect.Value) {
+	if s.done.Load() {
+		return
+	}
+	if s.filter.IsValid() && !s.filter.Call(in)[0].Bool() {
+		return
+	}
+	if s.queue != nil {
+		s.queue.push(s, in)
+		return
+	}
+	defer s.bus.enter(etype, s)()
+	s.call(in)
+}
+
+// calls the callback unless the subscription ended
+func (s *Subscription) call(in []reflect.Value) {
+	if s.done.Load() {
+		return
+	}
+	if s.once {
+		if !s.done.CompareAndSwap(false, true) {
+			return
+		}
+		if s.prefix != nil {
+			s.bus.removeWildcard(s)
+		} else {
+			s.bus.remove(s)
+		}
+	}
+	s.cb.Call(in)
+}
+
+// the filter has to accept the callbacks argument
+func (s *Subscription) validFilter() bool {
+	if !s.filter.IsValid() {
+		return true
+	}
+	ft, cbt := s.filter.Type(), s.cb.Type()
+	return ft.Kind() == reflect.Func && cbt.NumIn() == 1 &&
+		ft.NumIn() == 1 && ft.In(0) == cbt.In(0) &&
+		ft.NumOut() == 1 && ft.Out(0).Kind() == reflect.Bool
+}
+
+func (bus *eventBus) remove(s *Subscription) bool {
+	removed := false
+	modifier := func(value eventBusData) (eventBusData, bool) {
+		// the slice may be iterated by a publish, so it is copied
+		subscriptions := make([]*Subscription, 0, len(value.subscriptions))
+		for _, other := range value.subscriptions {
+			if other == s {
+				removed = true
+				continue
+			}
+			subscriptions = append(subscriptions, other)
+		}
+		value.subscriptions = subscriptions
+		return value, true
+	}
+	bus.data.Update(s.etype, modifier)
+	return removed
 }
 
 func NewEventbus() EventBus {
 	data := smap.NewSMap[EventType, eventBusData]()
-	return &eventBus{data}
+	bus := &eventBus{data: data, calling: make(map[*callToken]struct{})}
+	bus.Intercept(traceEvents)
+	return bus
+}
+
+// awaits the next occurence of an event
+type waiter struct {
+	reply chan any            // buffered, receives the data of the event
+	match func(data any) bool // nil for any event
+}
+
+// returns the data of the next event of the type, or the error of ctx if it
+// is done before. Fails right away from a callback of the same event type.
+func (bus *eventBus) AwaitEvent(ctx context.Context, etype EventType) (any, error) {
+	return bus.await(ctx, etype, nil, nil)
+}
+
+// Request publishes the event and returns the data of the first reply event
+// the match function accepts, nil accepts any. Since the reply is awaited
+// before publishing it can not be missed. The match function is called while
+// the bus is locked, so it may not use the bus.
+func (bus *eventBus) Request(ctx context.Context, e Event, reply EventType, match func(data any) bool) (any, error) {
+	return bus.await(ctx, reply, match, &e)
 }
 
-func (bus *eventBus) AwaitEvent(ctx context.Context, etype EventType) {
-	wait := make(chan bool)
+func (bus *eventBus) await(ctx context.Context, etype EventType, match func(data any) bool, request *Event) (any, error) {
+	trace := trace()
+	if bus.reentrant(etype) {
+		err := errors.New("awaiting an event from a callback of its type would deadlock")
+		log.Error(err, trace)
+		return nil, err
+	}
+	w := &waiter{reply: make(chan any, 1), match: match}
 
-	// append the wait channel
+	// append the waiter
 	modifier := func(value eventBusData) (eventBusData, bool) {
-		value.waitlist = append(value.waitlist, wait)
+		value.waitlist = append(value.waitlist, w)
 		return value, true
 	}
 	bus.data.Update(etype, modifier)
 
+	if request != nil && !bus.publish(*request, false) {
+		bus.removeWaiter(etype, w)
+		return nil, errors.New("request could not be published")
+	}
+
 	// await event occurence or context finalization
-	trace := trace()
 	log.Debug("Await Event ", etype, trace)
 	select {
 	case <-ctx.Done():
 		log.Debug("Cancel awaiting Event ", etype, trace)
-	case <-wait:
+		bus.removeWaiter(etype, w)
+		return nil, ctx.Err()
+	case data := <-w.reply:
 		log.Debug("Continue after Event ", etype, trace)
+		return data, nil
+	}
+}
+
+func (bus *eventBus) removeWaiter(etype EventType, w *waiter) {
+	modifier := func(value eventBusData) (eventBusData, bool) {
+		waitlist := make([]*waiter, 0, len(value.waitlist))
+		for _, other := range value.waitlist {
+			if other != w {
+				waitlist = append(waitlist, other)
+			}
+		}
+		value.waitlist = waitlist
+		return value, true
 	}
-	return
+	bus.data.Update(etype, modifier)
 }
 
+// Unbind ends the first subscription of cb. Closures sharing their code, e.g.
+// created by the same function, can not be told apart, which is why
+// Subscription.Unsubscribe should be preferred.
 func (bus *eventBus) Unbind(etype EventType, cb any) {
 	bus.unbind(etype, cb, false)
 }
@@ -101,14 +298,8 @@ func (bus *eventBus) unbindLogic(etype EventType, cb any, trace string) bool {
 	}
 
 	cbv := reflect.ValueOf(cb)
-	for i, registeredCB := range current.callbacks {
-		if registeredCB.Pointer() == cbv.Pointer() {
-			modifier := func(value eventBusData) (eventBusData, bool) {
-				value.callbacks = append(value.callbacks[:i], value.callbacks[i+1:]...)
-				return value, true
-			}
-
-			bus.data.Update(etype, modifier)
+	for _, s := range current.subscriptions {
+		if s.cb.Pointer() == cbv.Pointer() && s.Unsubscribe() {
 			log.Debug("Unbound callback from event: ", etype, trace)
 			return true
 		}
@@ -117,87 +308,153 @@ func (bus *eventBus) unbindLogic(etype EventType, cb any, trace string) bool {
 	return false
 }
 
-func (bus *eventBus) Bind(etype EventType, cb any) {
-	bus.bind(etype, cb, false)
+func (bus *eventBus) Bind(etype EventType, cb any, opts ...BindOption) *Subscription {
+	s, _ := bus.bind(etype, cb, opts, false)
+	return s
 }
 
-// returns whether the bind was successfull
-func (bus *eventBus) AwaitBind(etype EventType, cb any) bool {
-	return bus.bind(etype, cb, true)
+// returns whether the bind was successfull, once the callback was called with
+// the most recent event. Like AwaitPublish it does not wait when used from a
+// callback of the same event type.
+func (bus *eventBus) AwaitBind(etype EventType, cb any, opts ...BindOption) (*Subscription, bool) {
+	return bus.bind(etype, cb, opts, true)
 }
 
-// TODO : add options to allow for only once or make that a different method ?
-func (bus *eventBus) bind(etype EventType, cb any, await bool) bool {
+// the callback is called at most once, see Once
+func (bus *eventBus) BindOnce(etype EventType, cb any, opts ...BindOption) *Subscription {
+	return bus.Bind(etype, cb, append(opts, Once())...)
+}
+
+// the subscription is added right away, so it receives every event published
+// afterwards. Only the call with the most recent event is done asynchronously,
+// unless awaited.
+func (bus *eventBus) bind(etype EventType, cb any, opts []BindOption, await bool) (*Subscription, bool) {
+	s := &Subscription{bus: bus, etype: etype, cb: reflect.ValueOf(cb), name: funcName(cb)}
+	for _, opt := range opts {
+		opt(s)
+	}
+
 	trace := trace()
-	if await {
-		return bus.bindLogic(etype, cb, trace)
+	recent, prev, next, ok := bus.bindLogic(s, trace)
+	if !ok {
+		return s, false
+	}
+	if await && !bus.awaitsItself(s.etype, recent != nil, trace) {
+		bus.replay(s, recent, prev, next)
 	} else {
-		go bus.bindLogic(etype, cb, trace)
+		go bus.replay(s, recent, prev, next)
 	}
-	return true
+	return s, true
 }
 
-func (bus *eventBus) bindLogic(etype EventType, cb any, trace string) bool {
-	cbv := reflect.ValueOf(cb)
+// adds the subscription and takes a place in the delivery order for the call
+// with the most recent event, if present
+func (bus *eventBus) bindLogic(s *Subscription, trace string) (*Event, chan struct{}, chan struct{}, bool) {
+	cbType := s.cb.Type()
+	if !s.validFilter() {
+		log.Error(errors.New("filter does not match callback arg type"), trace)
+		s.done.Store(true)
+		return nil, nil, nil, false
+	}
 
-	// append callback
+	var prev, next chan struct{}
 	modifier := func(value eventBusData) (eventBusData, bool) {
 		if value.cbType == nil {
-			value.cbType = reflect.TypeOf(cb)
+			value.cbType = cbType
 		}
 
-		if value.cbType != reflect.TypeOf(cb) {
+		if value.cbType != cbType {
 			return value, false
 		}
 
-		value.callbacks = append(value.callbacks, cbv)
+		// the slice may be iterated by a publish, so it is copied
+		subscriptions := make([]*Subscription, len(value.subscriptions), len(value.subscriptions)+1)
+		copy(subscriptions, value.subscriptions)
+		value.subscriptions = append(subscriptions, s)
+
+		if value.recent != nil {
+			prev, next = value.tail, make(chan struct{})
+			value.tail = next
+		}
 		return value, true
 	}
 
-	current, ok := bus.data.Update(etype, modifier)
+	current, ok := bus.data.Update(s.etype, modifier)
 	if !ok {
-		return false
+		s.done.Store(true)
+		return nil, nil, nil, false
 	}
 
-	log.Debug("Bound func to event type : ", etype, trace)
+	log.Debug("Bound func to event type : ", s.etype, trace)
+	return current.recent, prev, next, true
+}
 
-	// execute callback with most recent event, if present
-	if current.recent != nil {
-		in := []reflect.Value{}
-		if current.recent.Data != nil {
-			arg := reflect.ValueOf(current.recent.Data)
-			in = append(in, arg)
-		}
-		cbv.Call(in)
+// executes the callback with the most recent event, once the events published
+// before are delivered
+func (bus *eventBus) replay(s *Subscription, recent *Event, prev, next chan struct{}) {
+	if recent == nil {
+		return
 	}
+	if prev != nil {
+		<-prev
+	}
+	defer close(next)
 
-	return true
+	in := []reflect.Value{}
+	if recent.Data != nil {
+		arg := reflect.ValueOf(recent.Data)
+		in = append(in, arg)
+	}
+	s.deliver(recent.Type, in)
 }
 
 func (bus *eventBus) Publish(e Event) {
 	bus.publish(e, false)
 }
 
-// returns whether the publish was successfull
+// returns whether the publish was successfull, once the event is delivered to
+// all subscribers. Subscribers with a queue may not have been called yet.
+// From a callback of the same event type the event is only delivered once the
+// running callbacks return, so it is logged and not awaited.
 func (bus *eventBus) AwaitPublish(e Event) bool {
 	return bus.publish(e, true)
 }
 
 func (bus *eventBus) publish(e Event, await bool) bool {
-	trace := trace()
-	if await {
-		return bus.publishLogic(e, trace)
+	e, ok := bus.intercept(e)
+	if !ok {
+		return false
+	}
+	d, ok := bus.publishLogic(e)
+	if !ok {
+		return false
+	}
+	if await && !bus.awaitsItself(e.Type, true, trace()) {
+		d.run(bus)
 	} else {
-		go bus.publishLogic(e, trace)
+		go d.run(bus)
 	}
 
 	return true
 }
 
-func (bus *eventBus) publishLogic(e Event, trace string) bool {
+// a published event, delivered after the event published before it
+type delivery struct {
+	e             Event
+	subscriptions []*Subscription
+	wildcards     []*Subscription
+	waitlist      []*waiter
+	prev, next    chan struct{}
+}
 
-	// set the most recent event
+// sets the most recent event and takes the next place in the delivery order of
+// its type, which is why the event is delivered in the order it was published
+func (bus *eventBus) publishLogic(e Event) (*delivery, bool) {
 	cbSig := getFSignature(e.Data)
+	d := &delivery{e: e, next: make(chan struct{})}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
 	modifier := func(value eventBusData) (eventBusData, bool) {
 		if value.cbType == nil {
 			value.cbType = cbSig
@@ -208,46 +465,137 @@ func (bus *eventBus) publishLogic(e Event, trace string) bool {
 		}
 
 		value.recent = &e
+		d.subscriptions = value.subscriptions
+		// waiters for another reply keep waiting
+		var waitlist []*waiter
+		for _, w := range value.waitlist {
+			if w.match == nil || w.match(e.Data) {
+				d.waitlist = append(d.waitlist, w)
+			} else {
+				waitlist = append(waitlist, w)
+			}
+		}
+		value.waitlist = waitlist
+		d.prev = value.tail
+		value.tail = d.next
 		return value, true
 	}
-	current, _ := bus.data.Update(e.Type, modifier)
+	if _, ok := bus.data.Update(e.Type, modifier); !ok {
+		err := errors.New("event data type does not match callback arg type")
+		log.Error(err, trace())
+		return nil, false
+	}
 
-	// execute all callbacks for this event
-	log.Debug("Publish Event", e, " to ", len(current.callbacks), " callbacks ", trace)
-	if current.callbacks != nil {
-		for _, cbv := range current.callbacks {
-			// check if event data matches expected callback arg type
-			if cbSig != current.cbType {
-				err := errors.New("event data type does not match callback arg type")
-				log.Error(err)
-				return false
-			}
+	for _, s := range bus.wildcards {
+		if s.matches(e.Type) {
+			d.wildcards = append(d.wildcards, s)
+		}
+	}
+	return d, true
+}
 
-			in := []reflect.Value{}
-			if e.Data != nil {
-				arg := reflect.ValueOf(e.Data)
-				in = append(in, arg)
-			}
-			cbv.Call(in)
+func (d *delivery) run(bus *eventBus) {
+	if d.prev != nil {
+		<-d.prev
+	}
+	defer close(d.next)
+
+	// execute all callbacks for this event
+	in := []reflect.Value{}
+	if d.e.Data != nil {
+		arg := reflect.ValueOf(d.e.Data)
+		in = append(in, arg)
+	}
+	for _, s := range d.subscriptions {
+		s.deliver(d.e.Type, in)
+	}
+	if len(d.wildcards) > 0 {
+		in := []reflect.Value{reflect.ValueOf(d.e)}
+		for _, s := range d.wildcards {
+			s.deliver(d.e.Type, in)
 		}
 	}
 
 	// notify all waiting processes
-	if current.waitlist != nil {
-		for _, w := range current.waitlist {
-			w <- true
-			close(w)
+	for _, w := range d.waitlist {
+		w.reply <- d.e.Data
+	}
+}
+
+// marks the callback of the subscription as being called with an event of
+// the type, until the returned function is called
+func (bus *eventBus) enter(etype EventType, s *Subscription) func() {
+	t := &callToken{etype: etype, name: s.name}
+	bus.callingMu.Lock()
+	bus.calling[t] = struct{}{}
+	bus.callingMu.Unlock()
+
+	return func() {
+		bus.callingMu.Lock()
+		delete(bus.calling, t)
+		bus.callingMu.Unlock()
+	}
+}
+
+// whether the caller is a callback of the type, in which case awaiting
+// another delivery of the type would wait for itself. The stack is only looked
+// at while callbacks of the type are being called.
+func (bus *eventBus) reentrant(etype EventType) bool {
+	names := make(map[string]bool)
+	bus.callingMu.Lock()
+	for t := range bus.calling {
+		if t.etype == etype {
+			names[t.name] = true
+		}
+	}
+	bus.callingMu.Unlock()
+	if len(names) == 0 {
+		return false
+	}
+
+	pc := make([]uintptr, 64)
+	n := runtime.Callers(2, pc)
+	for n == len(pc) {
+		pc = make([]uintptr, 2*len(pc))
+		n = runtime.Callers(2, pc)
+	}
+	frames := runtime.CallersFrames(pc[:n])
+	for {
+		f, more := frames.Next()
+		if names[f.Function] {
+			return true
 		}
-		modifier := func(value eventBusData) (eventBusData, bool) {
-			value.waitlist = value.waitlist[:0]
-			return value, true
+		if !more {
+			return false
 		}
-		bus.data.Update(e.Type, modifier)
 	}
+}
 
+// whether a delivery that is to be awaited would wait for itself, which is
+// logged. Only checked if the delivery would wait for the one before it.
+func (bus *eventBus) awaitsItself(etype EventType, waits bool, trace string) bool {
+	if !waits || !bus.reentrant(etype) {
+		return false
+	}
+	err := errors.New("awaiting the delivery of an event from a callback of its type would deadlock, it is delivered asynchronously")
+	log.Error(err, " ", etype, trace)
 	return true
 }
 
+// name of the function as it shows up on the stack, method values are
+// called through a wrapper which is left out of the stack
+func funcName(cb any) string {
+	v := reflect.ValueOf(cb)
+	if v.Kind() != reflect.Func {
+		return ""
+	}
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return ""
+	}
+	return strings.TrimSuffix(f.Name(), "-fm")
+}
+
 func getFSignature(arg any) reflect.Type {
 	cbArgType := reflect.TypeOf(arg)
 	in := []reflect.Type{}
@@ -260,7 +608,7 @@ func getFSignature(arg any) reflect.Type {
 }
 
 // get the last caller file that is a user file (to avoid assembly source files
-// and such) and not eventbus.go
+// and such) and not part of the bus
 func trace() string {
 	if *log.LogLvlFlag < int(log.DebugLevel) {
 		return ""
@@ -274,7 +622,7 @@ func trace() string {
 	for more {
 		f, more = frames.Next()
 		fileName := filepath.Base(f.File)
-		isEB := strings.HasSuffix(fileName, "eventbus.go")
+		isEB := strings.HasSuffix(fileName, "eventbus.go") || fileName == "topic.go" || fileName == "intercept.go"
 		isUserCode := strings.HasPrefix(f.File, "/Users")
 		if isUserCode && !isEB {
 			trace := "\nCalled from  : " + f.File + ":" + strconv.Itoa(f.Line)
@@ -1,6 +1,7 @@
 package bus
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -45,7 +46,7 @@ func TestEventBus_Wrong_Bind_Arg_Type(t *testing.T) {
 
 	bus.AwaitPublish(Event{wrongArgEvt, "test"})
 
-	res := bus.AwaitBind(wrongArgEvt, func(d int) {})
+	_, res := bus.AwaitBind(wrongArgEvt, func(d int) {})
 	if res != false {
 		t.Errorf("Mismatching data types should have failed but not with a panic")
 	}
@@ -155,3 +156,268 @@ func TestEventBus_Unbind_Failure(t *testing.T) {
 		t.Error("unbind for non existing callback")
 	}
 }
+
+func TestSubscription_Unsubscribe_Shared_Code(t *testing.T) {
+	evt := EventType("unsubscribe-shared")
+
+	// closures of the same function share their code pointer
+	received := make(chan int, 2)
+	newCb := func(id int) func(int) {
+		return func(int) { received <- id }
+	}
+	first, _ := bus.AwaitBind(evt, newCb(1))
+	bus.AwaitBind(evt, newCb(2))
+
+	if !first.Unsubscribe() {
+		t.Error("Unsubscribe not successful")
+	}
+	if first.Unsubscribe() {
+		t.Error("Expected a second Unsubscribe to fail")
+	}
+
+	bus.AwaitPublish(Event{evt, 0})
+	if id := <-received; id != 2 {
+		t.Errorf("Expected the other subscription to remain, got %d", id)
+	}
+	select {
+	case id := <-received:
+		t.Errorf("Expected a single call, got another by %d", id)
+	default:
+	}
+}
+
+func TestSubscription_Once(t *testing.T) {
+	evt := EventType("once")
+
+	mu := sync.Mutex{}
+	counter := 0
+	bus.AwaitBind(evt, func(int) {
+		mu.Lock()
+		counter++
+		mu.Unlock()
+	}, Once())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bus.AwaitPublish(Event{evt, i})
+		}(i)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	if counter != 1 {
+		t.Errorf("Expected a single call, got %d", counter)
+	}
+	mu.Unlock()
+}
+
+func TestSubscription_Where(t *testing.T) {
+	evt := EventType("where")
+
+	received := make(chan NodeOutput, 3)
+	onlyNode3 := func(out NodeOutput) bool { return out.NodeId == 3 }
+	if _, ok := bus.AwaitBind(evt, func(out NodeOutput) {
+		received <- out
+	}, Where(onlyNode3)); !ok {
+		t.Fatal("Bind not successful")
+	}
+
+	for id := 1; id <= 3; id++ {
+		bus.AwaitPublish(Event{evt, NodeOutput{NodeId: id}})
+	}
+	if out := <-received; out.NodeId != 3 {
+		t.Errorf("Expected only the output of node 3, got %d", out.NodeId)
+	}
+	select {
+	case out := <-received:
+		t.Errorf("Expected a single call, got the output of %d", out.NodeId)
+	default:
+	}
+
+	// the filter has to accept the callbacks argument
+	if _, ok := bus.AwaitBind(evt, func(out NodeOutput) {}, Where(func(id int) bool { return true })); ok {
+		t.Error("Mismatching filter should have failed")
+	}
+}
+
+func TestEventBus_Publish_Order(t *testing.T) {
+	evt := EventType("ordered")
+	testCnt := 100
+
+	received := make(chan int, testCnt)
+	bus.AwaitBind(evt, func(d int) {
+		received <- d
+	})
+
+	for i := 0; i < testCnt; i++ {
+		bus.Publish(Event{evt, i})
+	}
+	for i := 0; i < testCnt; i++ {
+		if d := <-received; d != i {
+			t.Fatalf("Expected the events in publish order, got %d instead of %d", d, i)
+		}
+	}
+}
+
+func TestEventBus_Bind_Order(t *testing.T) {
+	evt := EventType("ordered-bind")
+	bus.AwaitPublish(Event{evt, 0})
+
+	// a bind receives the most recent event before those published after it
+	received := make(chan int, 2)
+	bus.Bind(evt, func(d int) {
+		received <- d
+	})
+	bus.Publish(Event{evt, 1})
+
+	if d := <-received; d != 0 {
+		t.Errorf("Expected the most recent event first, got %d", d)
+	}
+	if d := <-received; d != 1 {
+		t.Errorf("Expected the published event second, got %d", d)
+	}
+}
+
+func TestEventBus_Await_Reentrant(t *testing.T) {
+	evt := EventType("reentrant")
+
+	// awaiting the own event type from a callback does not hang
+	done := make(chan error, 1)
+	bus.AwaitBind(evt, func(d int) {
+		if d != 0 {
+			return
+		}
+		bus.AwaitPublish(Event{evt, 1})
+		bus.AwaitBind(evt, func(d int) {})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := bus.AwaitEvent(ctx, evt)
+		done <- err
+	})
+	bus.Publish(Event{evt, 0})
+
+	select {
+	case err := <-done:
+		if err == nil || err == context.DeadlineExceeded {
+			t.Errorf("Expected awaiting the own event type to fail right away, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Deadlock awaiting the own event type from a callback")
+	}
+}
+
+type reentrantReceiver struct {
+	evt  EventType
+	done chan error
+}
+
+//go:noinline
+func (r *reentrantReceiver) receive(d int) {
+	if d != 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := bus.AwaitEvent(ctx, r.evt)
+	r.done <- err
+}
+
+func TestEventBus_Await_Reentrant_Method(t *testing.T) {
+	// method values are called through a wrapper that is not on the stack
+	r := &reentrantReceiver{evt: EventType("reentrant-method"), done: make(chan error, 1)}
+	bus.Bind(r.evt, r.receive)
+	bus.Publish(Event{r.evt, 0})
+
+	select {
+	case err := <-r.done:
+		if err == nil || err == context.DeadlineExceeded {
+			t.Errorf("Expected awaiting the own event type to fail right away, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Deadlock awaiting the own event type from a method")
+	}
+}
+
+func TestEventBus_AwaitPublish_Other_Goroutine(t *testing.T) {
+	evt := EventType("await-other-goroutine")
+
+	// a callback of the type is running, but not on the awaiting goroutine
+	running, release := make(chan any), make(chan any)
+	var mu sync.Mutex
+	var received []int
+	bus.Bind(evt, func(d int) {
+		if d == 0 {
+			close(running)
+			<-release
+		}
+		mu.Lock()
+		received = append(received, d)
+		mu.Unlock()
+	})
+	bus.Publish(Event{evt, 0})
+	<-running
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	bus.AwaitPublish(Event{evt, 1})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 {
+		t.Errorf("Expected the awaited event to be delivered once AwaitPublish returns, got %v", received)
+	}
+}
+
+func TestEventBus_AwaitEvent_Data(t *testing.T) {
+	evt := EventType("await-data")
+	triggerEvt := EventType("await-data-trigger")
+	bus.AwaitBind(triggerEvt, func() {
+		bus.Publish(Event{evt, 7})
+	})
+
+	// the waiter is registered before the trigger is published
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	data, err := bus.Request(ctx, Event{triggerEvt, nil}, evt, nil)
+	if err != nil || data != 7 {
+		t.Errorf("Expected the data of the event, got %v %v", data, err)
+	}
+}
+
+func TestEventBus_Request(t *testing.T) {
+	requestEvt := EventType("request")
+	replyEvt := EventType("reply")
+
+	// replies right away with the double of the request and an unrelated reply
+	bus.AwaitBind(requestEvt, func(d int) {
+		bus.Publish(Event{replyEvt, -1})
+		bus.Publish(Event{replyEvt, d * 2})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	data, err := bus.Request(ctx, Event{requestEvt, 21}, replyEvt, func(data any) bool {
+		return data == 42
+	})
+	if err != nil || data != 42 {
+		t.Errorf("Expected the correlated reply, got %v %v", data, err)
+	}
+
+	// the deadline passes without a matching reply
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := bus.Request(ctx, Event{requestEvt, 1}, replyEvt, func(data any) bool {
+		return data == 0
+	}); err != context.DeadlineExceeded {
+		t.Errorf("Expected the request to time out, got %v", err)
+	}
+
+	if _, err := bus.Request(context.Background(), Event{requestEvt, "1"}, replyEvt, nil); err == nil {
+		t.Error("Expected a request of the wrong type to fail")
+	}
+}
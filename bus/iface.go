@@ -1,5 +1,7 @@
 package bus
 
+import "time"
+
 /* To avoid import cycles this file defines all application specific
 * event types that may be published, aswell as their embedded data structures.
 * Helpful guidelines for naming :
@@ -11,6 +13,8 @@ package bus
 * - The data structures names should describe the abstract information they carry.
     They do not need to be named with relation to their events or the entire
 	eventbus because they might be useful in other, unrelated places aswell.
+  - Every event type gets a topic, e.g. NodeDataChangeTopic, see topic.go.
+    Subscribing and publishing through it is checked by the compiler.
   - Declare wrappers even for simple data e.g. NodeId for int. Since the parameter
     types in Bind callbacks cannot be checked by the compiler, switching from int
 	to a more complex type later on would not be caught and refactoring becomes
@@ -21,14 +25,43 @@ package bus
 
 const NodeDataChangeEvt EventType = "node-data-change"
 
+var NodeDataChangeTopic = NewTopic[NodeData](NodeDataChangeEvt)
+
 type NodeData struct {
 	TargetId int
 	Data     any
 }
 
+const CustomSchemaChangeEvt EventType = "custom-schema-change"
+
+var CustomSchemaChangeTopic = NewTopic[CustomSchema](CustomSchemaChangeEvt)
+
+type CustomSchema struct {
+	Schema any // decoded json schema the custom data has to match, nil for none
+}
+
+// generates the custom data of all nodes, see core.GenerateCustom
+const CustomTemplateEvt EventType = "custom-template"
+const CustomTemplateResultEvt EventType = "custom-template-result"
+
+var CustomTemplateTopic = NewTopic[CustomTemplate](CustomTemplateEvt)
+var CustomTemplateResultTopic = NewTopic[CustomTemplateResult](CustomTemplateResultEvt)
+
+type CustomTemplate struct {
+	Template string
+	Seed     int64
+}
+
+type CustomTemplateResult struct {
+	Err string
+}
+
 const ConnectNodesEvt EventType = "connect-nodes"
 const DisconnectNodesEvt EventType = "disconnect-nodes"
 
+var ConnectNodesTopic = NewTopic[Connection](ConnectNodesEvt)
+var DisconnectNodesTopic = NewTopic[Connection](DisconnectNodesEvt)
+
 type Connection struct {
 	From int
 	To   int
@@ -39,20 +72,42 @@ const StopNodesEvt EventType = "stop-nodes"
 const DebugNodesEvt EventType = "debug-nodes"
 const ContinueNodesEvt EventType = "continue-nodes"
 
+var StartNodesTopic = NewSignalTopic(StartNodesEvt)
+var StopNodesTopic = NewSignalTopic(StopNodesEvt)
+var DebugNodesTopic = NewSignalTopic(DebugNodesEvt)
+var ContinueNodesTopic = NewSignalTopic(ContinueNodesEvt)
+
 const CodeChangeEvt EventType = "code-change"
 
+var CodeChangeTopic = NewTopic[Code](CodeChangeEvt)
+
 type Code string
 
+const CodeCompileResultEvt EventType = "code-compile-result"
+
+var CodeCompileResultTopic = NewTopic[CodeCompileResult](CodeCompileResultEvt)
+
+// published once per code change
+type CodeCompileResult struct {
+	Err string // empty if the code compiles
+}
+
 const NodeCntChangeEvt EventType = "node-count-change"
 
+var NodeCntChangeTopic = NewTopic[int](NodeCntChangeEvt)
+
 type NodeCnt int
 
 const NetworkConnectionsEvt EventType = "network-connections"
 
+var NetworkConnectionsTopic = NewTopic[Connections](NetworkConnectionsEvt)
+
 type Connections []Connection
 
 const NetworkResizeEvt EventType = "network-resize"
 
+var NetworkResizeTopic = NewTopic[NetworkResize](NetworkResizeEvt)
+
 type NetworkResize struct {
 	Connections
 	Cnt int
@@ -60,6 +115,8 @@ type NetworkResize struct {
 
 const NodeOutputEvt EventType = "node-output"
 
+var NodeOutputTopic = NewTopic[NodeOutput](NodeOutputEvt)
+
 type NodeOutput struct {
 	Log    string
 	Result any
@@ -68,19 +125,30 @@ type NodeOutput struct {
 
 const SentToEvt EventType = "sent-to"
 
+var SentToTopic = NewTopic[SendTask](SentToEvt)
+
 type SendTask struct {
 	From int
 	To   int
 	Data any
 }
 
+const NodeFinishedEvt EventType = "node-finished"
+
+var NodeFinishedTopic = NewTopic[NodeId](NodeFinishedEvt)
+
 const AwaitStartEvt EventType = "await-start"
 const AwaitEndEvt EventType = "await-end"
 
+var AwaitStartTopic = NewTopic[NodeId](AwaitStartEvt)
+var AwaitEndTopic = NewTopic[[]SendTask](AwaitEndEvt)
+
 type NodeId int // TODO : if we keep this, other structs should use it aswell
 
 const FileOpenEvt EventType = "file-open"
 
+var FileOpenTopic = NewTopic[File](FileOpenEvt)
+
 type FileSource string
 
 const (
@@ -92,3 +160,294 @@ type File struct {
 	Path   string
 	Source FileSource
 }
+
+const CheckConfigChangeEvt EventType = "check-config-change"
+
+var CheckConfigChangeTopic = NewTopic[CheckConfig](CheckConfigChangeEvt)
+
+// which checks to run over the node results once a run has finished
+type CheckConfig struct {
+	Agreement        bool
+	Validity         bool
+	ValidityKey      string // proposals are read from this key of the custom data, empty means the entire custom data
+	Uniqueness       bool
+	UniquenessMarker any
+}
+
+const RunVerdictEvt EventType = "run-verdict"
+
+var RunVerdictTopic = NewTopic[Verdict](RunVerdictEvt)
+
+type CheckResult struct {
+	Name   string
+	Passed bool
+	Reason string
+}
+
+type Verdict struct {
+	Checks []CheckResult
+	Passed bool
+}
+
+const MetricsEvt EventType = "metrics"
+
+var MetricsTopic = NewTopic[Metrics](MetricsEvt)
+
+type NodeMetrics struct {
+	NodeId        int
+	Sent          int
+	Received      int
+	BytesSent     int
+	BytesReceived int
+	AwaitTime     time.Duration // time spent blocked in fAwait
+	Rounds        int           // number of fAwait calls
+}
+
+type EdgeMetrics struct {
+	Connection
+	Messages    int
+	Bytes       int
+	Lost        int
+	Retransmits int           // included in Messages
+	InFlight    int           // sent but neither received, discarded nor lost
+	Latency     time.Duration // average time between send and receive
+}
+
+type Metrics struct {
+	Elapsed  time.Duration
+	Nodes    []NodeMetrics
+	Edges    []EdgeMetrics
+	Messages int
+	Bytes    int
+	Rounds   int  // maximum rounds of any node
+	Final    bool // set for the summary published once a run has stopped
+}
+
+const LinkConfigChangeEvt EventType = "link-config-change"
+
+var LinkConfigChangeTopic = NewTopic[LinkConfig](LinkConfigChangeEvt)
+
+type LinkConfig struct {
+	Loss float64 // probability for a message to get lost
+	Seed int64   // seeds the random number generator, 0 picks a random seed
+}
+
+const CodeExportEvt EventType = "code-export"
+const CodeExportResultEvt EventType = "code-export-result"
+
+var CodeExportTopic = NewTopic[ExportTarget](CodeExportEvt)
+var CodeExportResultTopic = NewTopic[ExportResult](CodeExportResultEvt)
+
+type ExportTarget struct {
+	Dir string
+}
+
+type ExportResult struct {
+	Dir string
+	Err string // empty on success
+}
+
+const BackendChangeEvt EventType = "backend-change"
+
+var BackendChangeTopic = NewTopic[Backend](BackendChangeEvt)
+
+// defines where the nodes code is executed
+type BackendKind string
+
+const (
+	GoroutineBackend BackendKind = "goroutine" // interpreted, within the emulators process
+	ProcessBackend   BackendKind = "process"   // interpreted, one child process per node
+	NativeBackend    BackendKind = "native"    // compiled with the go toolchain, one child process per node
+	ExternalBackend  BackendKind = "external"  // any program speaking nodeproto over stdin/stdout, one per node
+)
+
+type Backend struct {
+	Kind    BackendKind
+	Command string // external only, the program and its arguments separated by spaces
+}
+
+const MessageModeChangeEvt EventType = "message-mode-change"
+
+var MessageModeChangeTopic = NewTopic[MessageMode](MessageModeChangeEvt)
+
+// defines how the data of a message is passed from the sender to the receiver
+type MessageModeKind string
+
+const (
+	CopyMessages   MessageModeKind = "copy"   // deep copy, types are preserved (default)
+	EncodeMessages MessageModeKind = "encode" // round trip through json
+	ShareMessages  MessageModeKind = "share"  // no copy, sender and receiver may share memory
+)
+
+type MessageMode struct {
+	Kind MessageModeKind
+}
+
+const CallEvt EventType = "call"
+const CallReturnEvt EventType = "call-return"
+
+var CallTopic = NewTopic[Call](CallEvt)
+var CallReturnTopic = NewTopic[Call](CallReturnEvt)
+
+// a request (CallEvt) or its response (CallReturnEvt) of fCall, both share
+// the Id
+type Call struct {
+	Id   int
+	From int
+	To   int
+	Data any
+}
+
+const ReliabilityChangeEvt EventType = "reliability-change"
+
+var ReliabilityChangeTopic = NewTopic[Reliability](ReliabilityChangeEvt)
+
+// delivery guarantee of a connection on top of the possibly lossy link
+type ReliabilityKind string
+
+const (
+	Unreliable  ReliabilityKind = "unreliable"    // messages may get lost (default)
+	AtLeastOnce ReliabilityKind = "at-least-once" // retransmitted until acknowledged (simulated), may be duplicated
+	ExactlyOnce ReliabilityKind = "exactly-once"  // retransmitted, duplicates discarded, in order
+)
+
+type Reliability struct {
+	Kind        ReliabilityKind
+	Connections Connections   // connections to apply it to, empty for all others
+	Timeout     time.Duration // until a message is retransmitted, 0 for the default
+}
+
+const ChurnConfigChangeEvt EventType = "churn-config-change"
+
+var ChurnConfigChangeTopic = NewTopic[Churn](ChurnConfigChangeEvt)
+
+// how nodes join and leave the network while it runs
+type ChurnKind string
+
+const (
+	NoChurn      ChurnKind = "none"    // nodes stay online (default)
+	PoissonChurn ChurnKind = "poisson" // joins arrive as a poisson process, every node stays online for a random session
+	TraceChurn   ChurnKind = "trace"   // joins and leaves are read from a trace file
+)
+
+// distribution of the time a node stays online
+type SessionKind string
+
+const (
+	ExponentialSession SessionKind = "exponential"
+	ParetoSession      SessionKind = "pareto"  // heavy tailed, Shape > 1
+	WeibullSession     SessionKind = "weibull" // Shape < 1 is heavy tailed
+	FixedSession       SessionKind = "fixed"
+)
+
+type Session struct {
+	Kind  SessionKind
+	Mean  time.Duration
+	Shape float64 // pareto and weibull only
+}
+
+type Churn struct {
+	Kind        ChurnKind
+	ArrivalRate float64 // joins per second, poisson only
+	Session     Session // poisson only
+	Trace       string  // path of the trace file, trace only
+	Bootstrap   string  // how joining nodes are connected, see core.Bootstrap
+	Seed        int64   // 0 picks a random seed
+}
+
+const ChurnConfigResultEvt EventType = "churn-config-result"
+
+var ChurnConfigResultTopic = NewTopic[ChurnConfigResult](ChurnConfigResultEvt)
+
+type ChurnConfigResult struct {
+	Err string // empty if the config was applied
+}
+
+const NodeStateEvt EventType = "node-state"
+
+var NodeStateTopic = NewTopic[NodeState](NodeStateEvt)
+
+// published whenever a node joins or leaves the network
+type NodeState struct {
+	NodeId int
+	Online bool
+}
+
+const GeoConfigChangeEvt EventType = "geo-config-change"
+
+var GeoConfigChangeTopic = NewTopic[Geo](GeoConfigChangeEvt)
+
+// where nodes are located, which determines the latency of their links
+type Placement string
+
+const (
+	CirclePlacement Placement = "circle" // drawn on a circle, links have the base latency only (default)
+	RandomPlacement Placement = "random" // uniformly distributed within the area
+	CustomPlacement Placement = "custom" // the "x" and "y" keys of the nodes custom data
+	ManualPlacement Placement = "manual" // set per node with NodePositionChangeEvt
+)
+
+type Geo struct {
+	Placement Placement
+	Size      float64       // side length of the square area, 0 for the default
+	Latency   time.Duration // of every link regardless of the distance
+	PerUnit   time.Duration // added per unit of distance between the nodes
+	Range     float64       // if set, nodes are connected exactly if within range of each other
+	Seed      int64         // random placement only, 0 picks a random seed
+}
+
+const GeoConfigResultEvt EventType = "geo-config-result"
+
+var GeoConfigResultTopic = NewTopic[GeoConfigResult](GeoConfigResultEvt)
+
+type GeoConfigResult struct {
+	Err string // empty if the config was applied
+}
+
+const NodePositionChangeEvt EventType = "node-position-change"
+
+var NodePositionChangeTopic = NewTopic[NodePosition](NodePositionChangeEvt)
+
+type Position struct {
+	X float64
+	Y float64
+}
+
+type NodePosition struct {
+	NodeId int
+	Position
+}
+
+const NodePositionsEvt EventType = "node-positions"
+
+var NodePositionsTopic = NewTopic[NodePositions](NodePositionsEvt)
+
+// published whenever nodes moved, Positions is nil for circle placement
+type NodePositions struct {
+	Size      float64
+	Range     float64
+	Positions []Position // by node id
+}
+
+const ByzantineChangeEvt EventType = "byzantine-change"
+
+var ByzantineChangeTopic = NewTopic[Byzantine](ByzantineChangeEvt)
+
+// how a node deviates from the protocol
+type ByzantineKind string
+
+const (
+	Honest              ByzantineKind = "honest"     // follows the code (default)
+	DropByzantine       ByzantineKind = "drop"       // sends nothing, fSend still reports the nodes as reached
+	EquivocateByzantine ByzantineKind = "equivocate" // peers with an odd id receive corrupted data, the others the real one
+	ReplayByzantine     ByzantineKind = "replay"     // sends a message it sent before instead, the first one is real
+	CorruptByzantine    ByzantineKind = "corrupt"    // every message is corrupted
+	CodeByzantine       ByzantineKind = "code"       // runs Code instead of the code of the other nodes
+)
+
+type Byzantine struct {
+	NodeId int
+	Kind   ByzantineKind
+	Code   string // code behaviour only, has to define Run like any code
+	Seed   int64  // 0 picks a random seed
+}
@@ -0,0 +1,158 @@
+package bus
+
+import (
+	"distributed-sys-emulator/log"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+/* Interceptors and wildcard subscriptions see the events of every type.
+*
+* An interceptor is called for every published event before its delivery, in
+* the goroutine of the publisher and in the order the interceptors were added.
+* It may pass the event on as is, modify it or drop it. Interceptors must not
+* block, since they hold up the publisher.
+*
+* A wildcard subscription receives the events of all types matching its
+* pattern, either "*" for every type or a prefix like "node-*". Its callback
+* takes the event instead of its data, otherwise it behaves like any other
+* subscription, it is called with the most recent events on bind and accepts
+* the same options.
+ */
+
+// Interceptor returns the event to deliver, or false to drop it
+type Interceptor func(e Event) (Event, bool)
+
+// Intercept adds the interceptor. Returns a function removing it.
+func (bus *eventBus) Intercept(i Interceptor) func() {
+	handle := &i
+	bus.mu.Lock()
+	bus.interceptors = append(bus.interceptors[:len(bus.interceptors):len(bus.interceptors)], handle)
+	bus.mu.Unlock()
+
+	return func() {
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		interceptors := make([]*Interceptor, 0, len(bus.interceptors))
+		for _, other := range bus.interceptors {
+			if other != handle {
+				interceptors = append(interceptors, other)
+			}
+		}
+		bus.interceptors = interceptors
+	}
+}
+
+// passes the event through all interceptors, false if one dropped it
+func (bus *eventBus) intercept(e Event) (Event, bool) {
+	bus.mu.Lock()
+	interceptors := bus.interceptors
+	bus.mu.Unlock()
+
+	for _, i := range interceptors {
+		var ok bool
+		if e, ok = (*i)(e); !ok {
+			return e, false
+		}
+	}
+	return e, true
+}
+
+// logs every published event along with where it was published from
+func traceEvents(e Event) (Event, bool) {
+	log.Debug("Publish Event", e, trace())
+	return e, true
+}
+
+func (bus *eventBus) BindWildcard(pattern string, cb func(e Event), opts ...BindOption) *Subscription {
+	s, _ := bus.bindWildcard(pattern, cb, opts, false)
+	return s
+}
+
+// returns whether the bind was successfull, once the callback was called with
+// the most recent events. Like AwaitBind it does not wait for the most recent
+// event of a type it is called from a callback of, which is delivered
+// asynchronously instead.
+func (bus *eventBus) AwaitBindWildcard(pattern string, cb func(e Event), opts ...BindOption) (*Subscription, bool) {
+	return bus.bindWildcard(pattern, cb, opts, true)
+}
+
+func (bus *eventBus) bindWildcard(pattern string, cb func(e Event), opts []BindOption, await bool) (*Subscription, bool) {
+	prefix, found := strings.CutSuffix(pattern, "*")
+	s := &Subscription{bus: bus, prefix: &prefix, cb: reflect.ValueOf(cb), name: funcName(cb)}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	trace := trace()
+	if !found || strings.Contains(prefix, "*") {
+		log.Error(errors.New("wildcard pattern has to end with its only *"), trace)
+		s.done.Store(true)
+		return s, false
+	}
+	if !s.validFilter() {
+		log.Error(errors.New("filter does not match callback arg type"), trace)
+		s.done.Store(true)
+		return s, false
+	}
+
+	// the subscription and its places in the delivery orders are taken at once,
+	// so no event is missed or received twice
+	type replay struct {
+		e          Event
+		prev, next chan struct{}
+	}
+	var replays []replay
+	bus.mu.Lock()
+	bus.wildcards = append(bus.wildcards[:len(bus.wildcards):len(bus.wildcards)], s)
+	for _, etype := range bus.data.Keys() {
+		if !s.matches(etype) {
+			continue
+		}
+		modifier := func(value eventBusData) (eventBusData, bool) {
+			if value.recent == nil {
+				return value, false
+			}
+			r := replay{e: *value.recent, prev: value.tail, next: make(chan struct{})}
+			value.tail = r.next
+			replays = append(replays, r)
+			return value, true
+		}
+		bus.data.Update(etype, modifier)
+	}
+	bus.mu.Unlock()
+	log.Debug("Bound func to event types : ", pattern, trace)
+
+	deliver := func(r replay) {
+		if r.prev != nil {
+			<-r.prev
+		}
+		defer close(r.next)
+		s.deliver(r.e.Type, []reflect.Value{reflect.ValueOf(r.e)})
+	}
+	for _, r := range replays {
+		if await && !bus.awaitsItself(r.e.Type, true, trace) {
+			deliver(r)
+		} else {
+			go deliver(r)
+		}
+	}
+	return s, true
+}
+
+func (s *Subscription) matches(etype EventType) bool {
+	return strings.HasPrefix(string(etype), *s.prefix)
+}
+
+func (bus *eventBus) removeWildcard(s *Subscription) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	wildcards := make([]*Subscription, 0, len(bus.wildcards))
+	for _, other := range bus.wildcards {
+		if other != s {
+			wildcards = append(wildcards, other)
+		}
+	}
+	bus.wildcards = wildcards
+}
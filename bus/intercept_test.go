@@ -0,0 +1,136 @@
+package bus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntercept_Modify_And_Drop(t *testing.T) {
+	eb := NewEventbus()
+	evt := EventType("intercepted")
+
+	received := make(chan int, 2)
+	eb.AwaitBind(evt, func(d int) {
+		received <- d
+	})
+
+	// doubles even numbers and drops odd ones
+	remove := eb.Intercept(func(e Event) (Event, bool) {
+		if d, ok := e.Data.(int); ok && e.Type == evt {
+			e.Data = d * 2
+			return e, d%2 == 0
+		}
+		return e, true
+	})
+
+	if eb.AwaitPublish(Event{evt, 1}) {
+		t.Error("Expected the dropped event to fail")
+	}
+	eb.AwaitPublish(Event{evt, 2})
+	if d := <-received; d != 4 {
+		t.Errorf("Expected the modified event, got %d", d)
+	}
+
+	remove()
+	eb.AwaitPublish(Event{evt, 3})
+	if d := <-received; d != 3 {
+		t.Errorf("Expected the event as published after removal, got %d", d)
+	}
+}
+
+func TestBindWildcard(t *testing.T) {
+	eb := NewEventbus()
+	NodeFinishedTopic.AwaitPublish(eb, 1)
+	NodeCntChangeTopic.AwaitPublish(eb, 3)
+
+	received := make(chan Event, 10)
+	s, ok := eb.AwaitBindWildcard("node-*", func(e Event) {
+		received <- e
+	})
+	if !ok {
+		t.Fatal("Bind not successful")
+	}
+
+	// the most recent events of all matching types are received on bind
+	replayed := map[EventType]any{}
+	for i := 0; i < 2; i++ {
+		e := <-received
+		replayed[e.Type] = e.Data
+	}
+	if replayed[NodeFinishedEvt] != NodeId(1) || replayed[NodeCntChangeEvt] != 3 {
+		t.Errorf("Expected the recent events of both types, got %v", replayed)
+	}
+
+	StopNodesTopic.AwaitPublish(eb)
+	NodeOutputTopic.AwaitPublish(eb, NodeOutput{NodeId: 2})
+	if e := <-received; e.Type != NodeOutputEvt {
+		t.Errorf("Expected only events with the prefix, got %v", e)
+	}
+
+	s.Unsubscribe()
+	NodeOutputTopic.AwaitPublish(eb, NodeOutput{NodeId: 3})
+	select {
+	case e := <-received:
+		t.Errorf("Expected no events after unsubscribing, got %v", e)
+	default:
+	}
+}
+
+func TestBindWildcard_All_In_Order(t *testing.T) {
+	eb := NewEventbus()
+	testCnt := 100
+
+	received := make(chan Event, testCnt)
+	eb.AwaitBindWildcard("*", func(e Event) {
+		received <- e
+	}, Where(func(e Event) bool { return e.Type == "wildcard-ordered" }))
+
+	for i := 0; i < testCnt; i++ {
+		eb.Publish(Event{"wildcard-ordered", i})
+		eb.Publish(Event{"wildcard-other", i})
+	}
+	for i := 0; i < testCnt; i++ {
+		if e := <-received; e.Data != i {
+			t.Fatalf("Expected the events in publish order, got %v instead of %d", e.Data, i)
+		}
+	}
+
+	if _, ok := eb.AwaitBindWildcard("node", func(e Event) {}); ok {
+		t.Error("Expected a pattern without * to fail")
+	}
+	if _, ok := eb.AwaitBindWildcard("*-evt*", func(e Event) {}); ok {
+		t.Error("Expected a pattern with an inner * to fail")
+	}
+}
+
+func TestBindWildcard_From_Callback(t *testing.T) {
+	eb := NewEventbus()
+	NodeCntChangeTopic.AwaitPublish(eb, 3)
+
+	// the replay of the type being delivered does not wait for the delivery
+	received := make(chan Event, 10)
+	bound := make(chan bool, 1)
+	StartNodesTopic.AwaitSubscribe(eb, func() {
+		_, ok := eb.AwaitBindWildcard("*", func(e Event) {
+			received <- e
+		})
+		bound <- ok
+	})
+	StartNodesTopic.Publish(eb)
+
+	select {
+	case ok := <-bound:
+		if !ok {
+			t.Fatal("Bind not successful")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Deadlock binding a wildcard from a callback")
+	}
+	replayed := map[EventType]bool{}
+	for i := 0; i < 2; i++ {
+		replayed[(<-received).Type] = true
+	}
+	if !replayed[StartNodesEvt] || !replayed[NodeCntChangeEvt] {
+		t.Errorf("Expected the recent events of both types, got %v", replayed)
+	}
+}
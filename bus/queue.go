@@ -0,0 +1,99 @@
+package bus
+
+import (
+	"reflect"
+	"sync"
+)
+
+/* A subscriber with a queue is not called by the publisher. The events are
+* buffered in its queue instead and a worker calls the subscriber in their
+* order, so a slow subscriber, e.g. one redrawing the gui, does not hold up
+* the publishers and the other subscribers. Like the bus, the worker does not
+* keep running, it ends once the queue is empty.
+ */
+
+// Overflow decides what happens to an event published to a full queue
+type Overflow int
+
+const (
+	// Block waits for the subscriber to make room, holding up the delivery of
+	// the following events of the type
+	Block Overflow = iota
+	// DropOldest drops the oldest queued event for the new one
+	DropOldest
+	// CoalesceLatest replaces the newest queued event by the new one, which
+	// suits events that carry a state, as the latest state is never dropped
+	CoalesceLatest
+)
+
+// Queue buffers up to size events for the subscriber, see Overflow
+func Queue(size int, overflow Overflow) BindOption {
+	if size < 1 {
+		size = 1
+	}
+	return func(s *Subscription) {
+		s.queue = &queue{size: size, overflow: overflow}
+		s.queue.space = sync.NewCond(&s.queue.mu)
+	}
+}
+
+type queue struct {
+	mu       sync.Mutex
+	space    *sync.Cond // signaled once an event is taken from the queue
+	events   [][]reflect.Value
+	size     int
+	overflow Overflow
+	working  bool
+}
+
+func (q *queue) push(s *Subscription, in []reflect.Value) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.events) >= q.size && q.overflow == Block && !s.done.Load() {
+		q.space.Wait()
+	}
+	if s.done.Load() {
+		return
+	}
+
+	switch {
+	case len(q.events) < q.size:
+		q.events = append(q.events, in)
+	case q.overflow == DropOldest:
+		q.events = append(q.events[1:], in)
+	case q.overflow == CoalesceLatest:
+		q.events[len(q.events)-1] = in
+	}
+
+	if !q.working {
+		q.working = true
+		go q.work(s)
+	}
+}
+
+// calls the subscriber until the queue is empty
+func (q *queue) work(s *Subscription) {
+	for {
+		q.mu.Lock()
+		if len(q.events) == 0 {
+			q.working = false
+			q.mu.Unlock()
+			return
+		}
+		in := q.events[0]
+		q.events = q.events[1:]
+		q.space.Broadcast()
+		q.mu.Unlock()
+
+		s.call(in)
+	}
+}
+
+// wakes blocked publishers once the subscription ended
+func (q *queue) release() {
+	q.mu.Lock()
+	q.events = nil
+	q.space.Broadcast()
+	q.mu.Unlock()
+}
@@ -0,0 +1,61 @@
+package bus
+
+import (
+	"testing"
+)
+
+// binds a subscriber and publishes 0 to 4, the subscriber is busy with the
+// first event until the others are published. Returns the events it received
+// in order.
+func publishToBusy(t *testing.T, eb EventBus, evt EventType, opt BindOption) chan int {
+	received := make(chan int, 10)
+	busy := make(chan any)
+	release := make(chan any)
+	if _, ok := eb.AwaitBind(evt, func(d int) {
+		if d == 0 {
+			close(busy)
+			<-release
+		}
+		received <- d
+	}, opt); !ok {
+		t.Fatal("Bind not successful")
+	}
+
+	eb.AwaitPublish(Event{evt, 0})
+	<-busy
+	for i := 1; i < 5; i++ {
+		if !eb.AwaitPublish(Event{evt, i}) {
+			t.Fatal("Publish not successful")
+		}
+	}
+	close(release)
+	return received
+}
+
+func expectReceived(t *testing.T, received chan int, expected ...int) {
+	for _, e := range expected {
+		if d := <-received; d != e {
+			t.Errorf("Expected %d, got %d", e, d)
+		}
+	}
+	select {
+	case d := <-received:
+		t.Errorf("Expected no more events, got %d", d)
+	default:
+	}
+}
+
+func TestQueue_Does_Not_Block_Publisher(t *testing.T) {
+	received := publishToBusy(t, NewEventbus(), "queue-block", Queue(4, Block))
+	expectReceived(t, received, 0, 1, 2, 3, 4)
+}
+
+func TestQueue_Drop_Oldest(t *testing.T) {
+	received := publishToBusy(t, NewEventbus(), "queue-drop", Queue(2, DropOldest))
+	expectReceived(t, received, 0, 3, 4)
+}
+
+func TestQueue_Coalesce_Latest(t *testing.T) {
+	received := publishToBusy(t, NewEventbus(), "queue-coalesce", Queue(2, CoalesceLatest))
+	expectReceived(t, received, 0, 1, 4)
+}
@@ -0,0 +1,125 @@
+package bus
+
+import (
+	"context"
+	"distributed-sys-emulator/log"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+/* A recording holds the events of one or more busses as json lines, each with
+* the time it was delivered at, relative to the start of the recording :
+*   {"at": 1500000, "type": "node-output", "data": {...}}
+* Replaying a recording publishes its events again, with their original or an
+* accelerated timing. This way a headless run can drive the gui, or an issue
+* of the gui can be reproduced.
+*
+* Only the events of a topic can be replayed, since their data type is needed
+* to decode them, see topic.go.
+ */
+
+type record struct {
+	At   time.Duration   `json:"at"`
+	Type EventType       `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type Recording struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	err   error // first error, writing stops after it
+}
+
+func NewRecording(w io.Writer) *Recording {
+	return &Recording{enc: json.NewEncoder(w), start: time.Now()}
+}
+
+// Record subscribes to every event of the bus, starting with the most recent
+// events. Unsubscribing ends the recording of the bus.
+func (r *Recording) Record(eb EventBus) *Subscription {
+	s, _ := eb.AwaitBindWildcard("*", r.write)
+	return s
+}
+
+// returns the first error that occured while recording
+func (r *Recording) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recording) write(e Event) {
+	rec := record{Type: e.Type}
+	if e.Data != nil {
+		raw, err := json.Marshal(e.Data)
+		if err != nil {
+			// e.g. a node result that can not be encoded, the event is left out
+			log.Error(err, " recording event ", e.Type)
+			return
+		}
+		rec.Data = raw
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	rec.At = time.Since(r.start)
+	r.err = r.enc.Encode(rec)
+}
+
+// Replay publishes the recorded events on the bus, speed times faster than
+// recorded. A speed of 0 or less publishes them without delay.
+func Replay(ctx context.Context, eb EventBus, r io.Reader, speed float64) error {
+	dec := json.NewDecoder(r)
+	start := time.Now()
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if speed > 0 {
+			delay := time.Duration(float64(rec.At)/speed) - time.Since(start)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		e, err := decodeEvent(rec.Type, rec.Data)
+		if err != nil {
+			log.Error(err, " replaying event ", rec.Type)
+			continue
+		}
+		eb.AwaitPublish(e)
+	}
+}
+
+// decodes the json data of an event into the data type of its topic
+func decodeEvent(etype EventType, raw json.RawMessage) (Event, error) {
+	dataType, ok := topicType(etype)
+	if !ok {
+		return Event{}, errors.New("unknown event type")
+	}
+	if dataType == nil {
+		return Event{Type: etype}, nil
+	}
+
+	data := reflect.New(dataType)
+	if err := json.Unmarshal(raw, data.Interface()); err != nil {
+		return Event{}, err
+	}
+	return Event{Type: etype, Data: data.Elem().Interface()}, nil
+}
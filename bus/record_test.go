@@ -0,0 +1,98 @@
+package bus
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecording_Replay(t *testing.T) {
+	recorded := NewEventbus()
+	NodeCntChangeTopic.AwaitPublish(recorded, 3)
+
+	var buf bytes.Buffer
+	rec := NewRecording(&buf)
+	s := rec.Record(recorded)
+	NodeOutputTopic.AwaitPublish(recorded, NodeOutput{NodeId: 1, Result: "a"})
+	StartNodesTopic.AwaitPublish(recorded)
+	s.Unsubscribe()
+	NodeCntChangeTopic.AwaitPublish(recorded, 4)
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := NewEventbus()
+	outputs := make(chan NodeOutput, 1)
+	NodeOutputTopic.AwaitSubscribe(replayed, func(out NodeOutput) {
+		outputs <- out
+	})
+	started := make(chan any, 1)
+	StartNodesTopic.AwaitSubscribe(replayed, func() {
+		started <- nil
+	})
+	cnts := make(chan int, 2)
+	NodeCntChangeTopic.AwaitSubscribe(replayed, func(cnt int) {
+		cnts <- cnt
+	})
+
+	if err := Replay(context.Background(), replayed, &buf, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// the most recent event is recorded on start, those after stop are not
+	if cnt := <-cnts; cnt != 3 || len(cnts) != 0 {
+		t.Errorf("Expected only the node count of 3 to be replayed, got %d", cnt)
+	}
+	if out := <-outputs; out.NodeId != 1 || out.Result != "a" {
+		t.Errorf("Expected the recorded output, got %v", out)
+	}
+	<-started
+}
+
+func TestReplay_Timing(t *testing.T) {
+	recording := `{"at":0,"type":"start-nodes"}
+{"at":400000000,"type":"stop-nodes"}
+`
+	eb := NewEventbus()
+	stopped := make(chan time.Time, 1)
+	StopNodesTopic.AwaitSubscribe(eb, func() {
+		stopped <- time.Now()
+	})
+
+	// 400ms replayed 4 times faster
+	start := time.Now()
+	if err := Replay(context.Background(), eb, strings.NewReader(recording), 4); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := (<-stopped).Sub(start); elapsed < 100*time.Millisecond || elapsed > 300*time.Millisecond {
+		t.Errorf("Expected the stop after 100ms, got %v", elapsed)
+	}
+
+	// replaying stops with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Replay(ctx, eb, strings.NewReader(recording), 1); err != context.DeadlineExceeded {
+		t.Errorf("Expected the replay to be cancelled, got %v", err)
+	}
+}
+
+func TestReplay_Unknown_Event(t *testing.T) {
+	recording := `{"at":0,"type":"unknown"}
+{"at":0,"type":"node-finished","data":2}
+`
+	eb := NewEventbus()
+	finished := make(chan NodeId, 1)
+	NodeFinishedTopic.AwaitSubscribe(eb, func(id NodeId) {
+		finished <- id
+	})
+
+	// unknown events are left out
+	if err := Replay(context.Background(), eb, strings.NewReader(recording), 0); err != nil {
+		t.Fatal(err)
+	}
+	if id := <-finished; id != 2 {
+		t.Errorf("Expected node 2 to finish, got %d", id)
+	}
+}
@@ -0,0 +1,163 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+/* Topics are a typed layer over the eventbus. A topic ties an EventType to the
+* type of its data, so the data published and the callbacks subscribed are
+* checked by the compiler instead of at runtime. The topics of all events are
+* declared next to their EventType in iface.go.
+*
+* Topics do not replace Bind and Publish, a subscribed callback is bound to the
+* topics EventType as is. It receives the events published without the topic
+* as long as the types match, and ends using the returned Subscription. T has to
+* be a concrete type, the bus matches the dynamic type of the published data.
+*
+* Every topic registers the type of its data, which lets the replayer and the
+* bridge decode events from json, see record.go and bridge.go.
+ */
+
+// EventType to the reflect.Type of its data, nil for signals
+var topicTypes sync.Map
+
+// calls f for every topic created so far
+func eachTopic(f func(etype EventType, dataType reflect.Type)) {
+	topicTypes.Range(func(key, value any) bool {
+		dataType, _ := value.(reflect.Type)
+		f(key.(EventType), dataType)
+		return true
+	})
+}
+
+func topicType(etype EventType) (reflect.Type, bool) {
+	value, ok := topicTypes.Load(etype)
+	dataType, _ := value.(reflect.Type)
+	return dataType, ok
+}
+
+// Topic of the events carrying data of type T
+type Topic[T any] struct {
+	Type EventType
+}
+
+func NewTopic[T any](etype EventType) Topic[T] {
+	topicTypes.Store(etype, reflect.TypeOf((*T)(nil)).Elem())
+	return Topic[T]{Type: etype}
+}
+
+func (t Topic[T]) Event(data T) Event {
+	return Event{Type: t.Type, Data: data}
+}
+
+func (t Topic[T]) Subscribe(eb EventBus, cb func(T)) *Subscription {
+	return eb.Bind(t.Type, cb)
+}
+
+// returns whether the subscription was successfull
+func (t Topic[T]) AwaitSubscribe(eb EventBus, cb func(T)) (*Subscription, bool) {
+	return eb.AwaitBind(t.Type, cb)
+}
+
+func (t Topic[T]) SubscribeOnce(eb EventBus, cb func(T)) *Subscription {
+	return eb.BindOnce(t.Type, cb)
+}
+
+// cb is only called for the data the filter accepts
+func (t Topic[T]) SubscribeWhere(eb EventBus, filter func(T) bool, cb func(T)) *Subscription {
+	return eb.Bind(t.Type, cb, Where(filter))
+}
+
+// returns whether the subscription was successfull
+func (t Topic[T]) AwaitSubscribeWhere(eb EventBus, filter func(T) bool, cb func(T)) (*Subscription, bool) {
+	return eb.AwaitBind(t.Type, cb, Where(filter))
+}
+
+func (t Topic[T]) Publish(eb EventBus, data T) {
+	eb.Publish(t.Event(data))
+}
+
+// returns whether the publish was successfull
+func (t Topic[T]) AwaitPublish(eb EventBus, data T) bool {
+	return eb.AwaitPublish(t.Event(data))
+}
+
+// returns the data of the next event, or the error of ctx if it is done before
+func (t Topic[T]) AwaitEvent(ctx context.Context, eb EventBus) (T, error) {
+	data, err := eb.AwaitEvent(ctx, t.Type)
+	return t.data(data, err)
+}
+
+// AwaitReply publishes the request and returns the data of the first event of
+// the topic the match function accepts, nil accepts any. See EventBus.Request.
+func (t Topic[T]) AwaitReply(ctx context.Context, eb EventBus, request Event, match func(T) bool) (T, error) {
+	data, err := eb.Request(ctx, request, t.Type, func(data any) bool {
+		reply, ok := data.(T)
+		return ok && (match == nil || match(reply))
+	})
+	return t.data(data, err)
+}
+
+func (t Topic[T]) data(data any, err error) (T, error) {
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	reply, ok := data.(T)
+	if !ok {
+		return reply, errors.New("event data does not match the topic")
+	}
+	return reply, nil
+}
+
+// SignalTopic of the events without data, e.g. StartNodesEvt
+type SignalTopic struct {
+	Type EventType
+}
+
+func NewSignalTopic(etype EventType) SignalTopic {
+	topicTypes.Store(etype, nil)
+	return SignalTopic{Type: etype}
+}
+
+func (t SignalTopic) Event() Event {
+	return Event{Type: t.Type}
+}
+
+func (t SignalTopic) Subscribe(eb EventBus, cb func()) *Subscription {
+	return eb.Bind(t.Type, cb)
+}
+
+// returns whether the subscription was successfull
+func (t SignalTopic) AwaitSubscribe(eb EventBus, cb func()) (*Subscription, bool) {
+	return eb.AwaitBind(t.Type, cb)
+}
+
+func (t SignalTopic) SubscribeOnce(eb EventBus, cb func()) *Subscription {
+	return eb.BindOnce(t.Type, cb)
+}
+
+func (t SignalTopic) Publish(eb EventBus) {
+	eb.Publish(t.Event())
+}
+
+// returns whether the publish was successfull
+func (t SignalTopic) AwaitPublish(eb EventBus) bool {
+	return eb.AwaitPublish(t.Event())
+}
+
+// returns the error of ctx if it is done before the next event
+func (t SignalTopic) AwaitEvent(ctx context.Context, eb EventBus) error {
+	_, err := eb.AwaitEvent(ctx, t.Type)
+	return err
+}
+
+// AwaitReply publishes the request and waits for the next event of the topic,
+// see EventBus.Request
+func (t SignalTopic) AwaitReply(ctx context.Context, eb EventBus, request Event) error {
+	_, err := eb.Request(ctx, request, t.Type, nil)
+	return err
+}
@@ -0,0 +1,116 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTopic_Subscribe_Publish(t *testing.T) {
+	eb := NewEventbus()
+	topic := NewTopic[NodeData]("topic-basic")
+
+	received := make(chan NodeData, 1)
+	if _, ok := topic.AwaitSubscribe(eb, func(data NodeData) {
+		received <- data
+	}); !ok {
+		t.Fatal("Subscription failed")
+	}
+
+	if !topic.AwaitPublish(eb, NodeData{TargetId: 2, Data: "a"}) {
+		t.Fatal("Publish failed")
+	}
+	if data := <-received; data.TargetId != 2 || data.Data != "a" {
+		t.Errorf("Expected the published data, got %v", data)
+	}
+}
+
+func TestTopic_Interop(t *testing.T) {
+	eb := NewEventbus()
+	topic := NewTopic[NodeId]("topic-interop")
+
+	// events published without the topic reach subscribers of the topic
+	received := make(chan NodeId, 2)
+	cb := func(id NodeId) {
+		received <- id
+	}
+	topic.AwaitSubscribe(eb, cb)
+	eb.AwaitPublish(Event{Type: topic.Type, Data: NodeId(1)})
+	if id := <-received; id != 1 {
+		t.Errorf("Expected 1, got %d", id)
+	}
+
+	// and vice versa, binding replays the most recent event
+	bound := make(chan NodeId, 2)
+	eb.AwaitBind(topic.Type, func(id NodeId) {
+		bound <- id
+	})
+	topic.AwaitPublish(eb, 2)
+	<-bound
+	if id := <-bound; id != 2 {
+		t.Errorf("Expected 2, got %d", id)
+	}
+	<-received
+
+	// the subscribed callback is bound as is
+	if !eb.AwaitUnbind(topic.Type, cb) {
+		t.Error("Expected the subscribed callback to be unbound")
+	}
+	topic.AwaitPublish(eb, 3)
+	select {
+	case id := <-received:
+		t.Errorf("Expected no event after unbinding, got %d", id)
+	default:
+	}
+
+	// data of the wrong type is still rejected
+	if eb.AwaitPublish(Event{Type: topic.Type, Data: 4}) {
+		t.Error("Expected an int to be rejected by a topic of NodeId")
+	}
+}
+
+func TestSignalTopic(t *testing.T) {
+	eb := NewEventbus()
+	topic := NewSignalTopic("signal")
+
+	called := make(chan any, 1)
+	topic.AwaitSubscribe(eb, func() {
+		called <- nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	awaited := make(chan error)
+	go func() {
+		awaited <- topic.AwaitEvent(ctx, eb)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	topic.Publish(eb)
+	<-called
+	if err := <-awaited; err != nil {
+		t.Errorf("Expected the event to be awaited, got %v", err)
+	}
+}
+
+func TestTopic_AwaitReply(t *testing.T) {
+	eb := NewEventbus()
+	request := NewTopic[NodeId]("topic-request")
+	reply := NewTopic[NodeOutput]("topic-reply")
+
+	// every node replies, only the requested one is awaited
+	request.AwaitSubscribe(eb, func(id NodeId) {
+		for i := 0; i < 3; i++ {
+			reply.Publish(eb, NodeOutput{NodeId: i, Result: i * 10})
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	out, err := reply.AwaitReply(ctx, eb, request.Event(2), func(out NodeOutput) bool {
+		return out.NodeId == 2
+	})
+	if err != nil || out.Result != 20 {
+		t.Errorf("Expected the output of node 2, got %v %v", out, err)
+	}
+}
@@ -0,0 +1,147 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+/* Checks over the results of a single run, e.g. to verify the properties of
+* consensus or election algorithms. Results are the values returned by each
+* nodes Run function, proposals are the values each node started out with
+* (usually taken from its custom data). Not every node has to take part, ids
+* holds the id of the node at every index, which the reasons refer to.
+ */
+
+// Comparator decides whether two values should be considered equal
+type Comparator func(a, b any) bool
+
+type Check interface {
+	Name() string
+	// whether the check can only be verified with the proposals of the nodes
+	NeedsProposals() bool
+	Verify(ids []int, results []any, proposals []any) error
+}
+
+// Equal is the default comparator. Both values are normalized through json so
+// that e.g. an int result matches a float64 parsed from a nodes custom data.
+func Equal(a, b any) bool {
+	na, errA := normalize(a)
+	nb, errB := normalize(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return reflect.DeepEqual(na, nb)
+}
+
+func normalize(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var res any
+	err = json.Unmarshal(b, &res)
+	return res, err
+}
+
+type check struct {
+	name      string
+	proposals bool
+	verify    func(ids []int, results []any, proposals []any) error
+}
+
+func (c check) Name() string {
+	return c.name
+}
+
+func (c check) NeedsProposals() bool {
+	return c.proposals
+}
+
+func (c check) Verify(ids []int, results []any, proposals []any) error {
+	return c.verify(ids, results, proposals)
+}
+
+// Custom wraps an arbitrary verification function as a Check, which gets nil
+// proposals if they could not be extracted
+func Custom(name string, verify func(results []any, proposals []any) error) Check {
+	return check{name, false, func(_ []int, results []any, proposals []any) error {
+		return verify(results, proposals)
+	}}
+}
+
+// Agreement passes if all nodes returned the same result
+func Agreement(eq Comparator) Check {
+	return check{"agreement", false, func(ids []int, results []any, _ []any) error {
+		for i := 1; i < len(results); i++ {
+			if !eq(results[0], results[i]) {
+				return fmt.Errorf("node %d returned %v but node %d returned %v",
+					ids[0], results[0], ids[i], results[i])
+			}
+		}
+		return nil
+	}}
+}
+
+// Validity passes if every result is one of the proposed values
+func Validity(eq Comparator) Check {
+	return check{"validity", true, func(ids []int, results []any, proposals []any) error {
+		for i, r := range results {
+			if !contains(proposals, r, eq) {
+				return fmt.Errorf("node %d returned %v which was never proposed", ids[i], r)
+			}
+		}
+		return nil
+	}}
+}
+
+// Uniqueness passes if exactly one node returned the marker, e.g. to verify
+// that exactly one leader has been elected
+func Uniqueness(marker any, eq Comparator) Check {
+	return check{"uniqueness", false, func(ids []int, results []any, _ []any) error {
+		var marked []int
+		for i, r := range results {
+			if eq(r, marker) {
+				marked = append(marked, ids[i])
+			}
+		}
+
+		if len(marked) == 0 {
+			return fmt.Errorf("no node returned %v", marker)
+		}
+		if len(marked) > 1 {
+			return fmt.Errorf("nodes %v all returned %v", marked, marker)
+		}
+		return nil
+	}}
+}
+
+func contains(values []any, v any, eq Comparator) bool {
+	for _, candidate := range values {
+		if eq(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Proposals extracts the proposed values from the nodes custom data. If key is
+// empty the custom data itself is the proposal, otherwise the value stored
+// under key in a json object.
+func Proposals(ids []int, custom []any, key string) ([]any, error) {
+	res := make([]any, len(custom))
+	for i, c := range custom {
+		if key == "" {
+			res[i] = c
+			continue
+		}
+
+		obj, ok := c.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("custom data of node %d is not a json object", ids[i])
+		}
+		res[i] = obj[key]
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package check
+
+import "testing"
+
+func TestAgreement(t *testing.T) {
+	agreement := Agreement(Equal)
+
+	if err := agreement.Verify([]int{0, 1, 2}, []any{1, 1.0, float32(1)}, nil); err != nil {
+		t.Errorf("Equal numbers of different types should agree : %v", err)
+	}
+
+	if err := agreement.Verify([]int{0, 1}, []any{1, 2}, nil); err == nil {
+		t.Error("Different results should not agree")
+	}
+}
+
+func TestValidity(t *testing.T) {
+	custom := []any{
+		map[string]any{"value": 3.},
+		map[string]any{"value": 5.},
+	}
+	proposals, err := Proposals([]int{0, 1}, custom, "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validity := Validity(Equal)
+	if !validity.NeedsProposals() || Agreement(Equal).NeedsProposals() {
+		t.Error("Only validity should need the proposals")
+	}
+	if err := validity.Verify([]int{0, 1}, []any{5, 5}, proposals); err != nil {
+		t.Errorf("Proposed result should be valid : %v", err)
+	}
+
+	if err := validity.Verify([]int{0, 1}, []any{5, 4}, proposals); err == nil {
+		t.Error("Result that was never proposed should be invalid")
+	}
+
+	if _, err := Proposals([]int{0}, []any{"foo"}, "value"); err == nil {
+		t.Error("Extracting a key from non object custom data should fail")
+	}
+}
+
+func TestUniqueness(t *testing.T) {
+	uniqueness := Uniqueness(true, Equal)
+
+	if err := uniqueness.Verify([]int{0, 1, 2}, []any{false, true, false}, nil); err != nil {
+		t.Errorf("Exactly one marker should pass : %v", err)
+	}
+
+	if err := uniqueness.Verify([]int{0, 1}, []any{false, false}, nil); err == nil {
+		t.Error("No marker should fail")
+	}
+
+	// reasons refer to the ids of the nodes
+	err := uniqueness.Verify([]int{1, 3}, []any{true, true}, nil)
+	if err == nil || err.Error() != "nodes [1 3] all returned true" {
+		t.Errorf("Multiple markers should fail, got %v", err)
+	}
+}
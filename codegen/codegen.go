@@ -0,0 +1,224 @@
+package codegen
+
+import (
+	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/nodeproto"
+	"distributed-sys-emulator/sim"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/* Generates a standalone go module from the users code which runs each node as
+* a separate process, communicating over tcp. The generated module contains :
+* - code.go, the users code
+* - runtime.go, the main function and the tcp implementation of fSend/fAwait
+* - types.go, registers the types declared in code.go with gob, so they may be
+*   sent as message data
+* - emulator/sim, a copy of the sim package so the code may import it
+* - config.json, node addresses, neighbors and custom data for local processes
+* - config.docker.json, docker-compose.yml and Dockerfile to run every node in
+*   its own container
+ */
+
+//go:embed templates/runtime.go.tmpl templates/native.go.tmpl
+var templates embed.FS
+
+const moduleName = "p2psim-nodes"
+
+// the emulators module, replaced by the copy of its sim package
+const emulatorModule = "distributed-sys-emulator"
+
+const goMod = "module " + moduleName + "\n\ngo 1.20\n\nrequire " + emulatorModule + " v0.0.0\n\nreplace " + emulatorModule + " => ./emulator\n"
+
+type Spec struct {
+	Code        string
+	Connections bus.Connections
+	Custom      []any // custom data per node, its length defines the node count
+	BasePort    int   // node i listens on BasePort+i, defaults to 7000
+}
+
+// same layout the generated runtime expects
+type nodeConfig struct {
+	Out    []int `json:"out-neighbors"`
+	In     []int `json:"in-neighbors"`
+	Custom any   `json:"custom"`
+}
+
+type networkConfig struct {
+	Addresses []string     `json:"addresses"`
+	Nodes     []nodeConfig `json:"nodes"`
+}
+
+func Generate(dir string, spec Spec) error {
+	if spec.BasePort == 0 {
+		spec.BasePort = 7000
+	}
+	nodeCnt := len(spec.Custom)
+
+	if err := os.MkdirAll(filepath.Join(dir, "emulator", "sim"), 0755); err != nil {
+		return err
+	}
+
+	runtime, err := templates.ReadFile("templates/runtime.go.tmpl")
+	if err != nil {
+		return err
+	}
+
+	nodes := make([]nodeConfig, nodeCnt)
+	for i := range nodes {
+		nodes[i] = nodeConfig{Out: []int{}, In: []int{}, Custom: spec.Custom[i]}
+	}
+	for _, c := range spec.Connections {
+		if c.From >= nodeCnt || c.To >= nodeCnt {
+			return fmt.Errorf("connection %d -> %d exceeds the node count %d", c.From, c.To, nodeCnt)
+		}
+		nodes[c.From].Out = append(nodes[c.From].Out, c.To)
+		nodes[c.To].In = append(nodes[c.To].In, c.From)
+	}
+
+	local := networkConfig{Nodes: nodes}
+	docker := networkConfig{Nodes: nodes}
+	for i := 0; i < nodeCnt; i++ {
+		local.Addresses = append(local.Addresses, fmt.Sprintf("127.0.0.1:%d", spec.BasePort+i))
+		docker.Addresses = append(docker.Addresses, fmt.Sprintf("node%d:%d", i, spec.BasePort))
+	}
+
+	types, err := gobTypes(spec.Code)
+	if err != nil {
+		return err
+	}
+
+	localConfig, err := json.MarshalIndent(local, "", "  ")
+	if err != nil {
+		return err
+	}
+	dockerConfig, err := json.MarshalIndent(docker, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		"go.mod":              []byte(goMod),
+		"emulator/go.mod":     []byte("module " + emulatorModule + "\n\ngo 1.20\n"),
+		"emulator/sim/sim.go": sim.Source,
+		"code.go":             []byte(spec.Code),
+		"runtime.go":          runtime,
+		"types.go":            types,
+		"config.json":         localConfig,
+		"config.docker.json":  dockerConfig,
+		"Dockerfile":          []byte(dockerfile),
+		"docker-compose.yml":  []byte(compose(nodeCnt, spec.BasePort)),
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), content, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GenerateNative writes a module which compiles the users code into the worker
+// of a single node for the native backend of the emulator. The worker speaks
+// nodeproto over stdin and stdout, instead of the code being interpreted.
+func GenerateNative(dir string, code string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "emulator", "sim"), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "emulator", "nodeproto"), 0755); err != nil {
+		return err
+	}
+
+	harness, err := templates.ReadFile("templates/native.go.tmpl")
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		"go.mod":                          []byte(goMod),
+		"emulator/go.mod":                 []byte("module " + emulatorModule + "\n\ngo 1.20\n"),
+		"emulator/sim/sim.go":             sim.Source,
+		"emulator/nodeproto/nodeproto.go": nodeproto.Source,
+		"code.go":                         []byte(code),
+		"main.go":                         harness,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), content, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// a file registering every type declared at the top level of the code with
+// gob, except for interfaces and generic types which have no values of their
+// own
+func gobTypes(code string) ([]byte, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "code.go", code, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.InterfaceType); ok || ts.Assign.IsValid() || ts.TypeParams != nil {
+				continue
+			}
+			names = append(names, ts.Name.Name)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by P2PSim. DO NOT EDIT.\n\npackage main\n")
+	if len(names) == 0 {
+		return []byte(b.String()), nil
+	}
+	b.WriteString("\nimport \"encoding/gob\"\n\n")
+	b.WriteString("// the types declared in code.go, so they may be sent as message data\n")
+	b.WriteString("func init() {\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "\tgob.Register(*new(%s))\n", name)
+	}
+	b.WriteString("}\n")
+	return []byte(b.String()), nil
+}
+
+const dockerfile = `FROM golang:1.20 AS build
+WORKDIR /src
+COPY . .
+RUN CGO_ENABLED=0 go build -o /node .
+
+FROM scratch
+COPY --from=build /node /node
+COPY config.docker.json /config.json
+ENTRYPOINT ["/node", "-config", "/config.json"]
+`
+
+func compose(nodeCnt, port int) string {
+	var b strings.Builder
+	b.WriteString("services:\n")
+	for i := 0; i < nodeCnt; i++ {
+		fmt.Fprintf(&b, "  node%d:\n", i)
+		b.WriteString("    build: .\n")
+		fmt.Fprintf(&b, "    hostname: node%d\n", i)
+		fmt.Fprintf(&b, "    command: [\"-id\", \"%d\"]\n", i)
+		fmt.Fprintf(&b, "    expose: [\"%d\"]\n", port)
+	}
+	return b.String()
+}
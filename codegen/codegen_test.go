@@ -0,0 +1,152 @@
+package codegen
+
+import (
+	"distributed-sys-emulator/bus"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testCode = `package main
+
+import (
+	"context"
+	"distributed-sys-emulator/sim"
+)
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	return sim.ID(ctx)
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	spec := Spec{
+		Code:        testCode,
+		Connections: bus.Connections{{From: 0, To: 1}, {From: 1, To: 2}},
+		Custom:      []any{"a", nil, 3.},
+	}
+
+	if err := Generate(dir, spec); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config networkConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(config.Nodes) != 3 || len(config.Addresses) != 3 {
+		t.Fatalf("Expected 3 nodes, got %d nodes and %d addresses", len(config.Nodes), len(config.Addresses))
+	}
+	if len(config.Nodes[1].In) != 1 || config.Nodes[1].In[0] != 0 {
+		t.Errorf("Node 1 should receive from node 0, got %v", config.Nodes[1].In)
+	}
+	if config.Nodes[0].Custom != "a" {
+		t.Errorf("Custom data not exported, got %v", config.Nodes[0].Custom)
+	}
+
+	// the generated module only depends on its copy of sim so it can be built
+	// offline
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	cmd := exec.Command("go", "build", "-o", filepath.Join(dir, "node"), ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("Generated module does not build : %v\n%s", err, out)
+	}
+}
+
+// node 0 sends json-like data holding a type of the code to node 1
+const sendCode = `package main
+
+import (
+	"context"
+	"fmt"
+)
+
+type Vote struct {
+	Term int
+}
+
+type Voter interface {
+	Vote() Vote
+}
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	if ctx.Value("id").(int) == 0 {
+		return fSend(1, map[string]any{"votes": []any{Vote{3}, 4}})
+	}
+	for _, msg := range fAwait(1) {
+		return fmt.Sprint(msg)
+	}
+	return nil
+}
+`
+
+func TestGenerate_Sends_Data(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	dir := t.TempDir()
+	spec := Spec{
+		Code:        sendCode,
+		Connections: bus.Connections{{From: 0, To: 1}},
+		Custom:      []any{nil, nil},
+		BasePort:    17300,
+	}
+	if err := Generate(dir, spec); err != nil {
+		t.Fatal(err)
+	}
+	node := filepath.Join(dir, "node")
+	build := exec.Command("go", "build", "-o", node, ".")
+	build.Dir = dir
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("Generated module does not build : %v\n%s", err, out)
+	}
+
+	results := make(chan string, 2)
+	for id := range spec.Custom {
+		cmd := exec.Command(node, "-id", strconv.Itoa(id), "-timeout", "10s")
+		cmd.Dir = dir
+		go func() {
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				out = append(out, err.Error()...)
+			}
+			results <- string(out)
+		}()
+	}
+
+	expected := map[string]bool{
+		`{"id":0,"result":1}`:                          true,
+		`{"id":1,"result":"{0 1 map[votes:[{3} 4]]}"}`: true,
+	}
+	for i := 0; i < 2; i++ {
+		if out := strings.TrimSpace(<-results); !expected[out] {
+			t.Errorf("Unexpected output of a node %s", out)
+		}
+	}
+}
+
+func TestGenerate_Invalid_Connection(t *testing.T) {
+	spec := Spec{
+		Code:        testCode,
+		Connections: bus.Connections{{From: 0, To: 5}},
+		Custom:      []any{nil, nil},
+	}
+
+	if err := Generate(t.TempDir(), spec); err == nil {
+		t.Error("Connection to a non existing node should fail")
+	}
+}
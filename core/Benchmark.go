@@ -0,0 +1,443 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/log"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how long to wait for the node outputs after stopping a run
+const stopTimeout = 5 * time.Second
+
+// BenchmarkConfig describes a grid of configurations, each of which is run
+// Repetitions times. Empty lists fall back to a single default value.
+type BenchmarkConfig struct {
+	CodePath    string               `json:"code"`
+	NodeCounts  []int                `json:"node-counts"`
+	Topologies  []string             `json:"topologies"` // see Topology for the supported names
+	Losses      []float64            `json:"losses"`
+	Seeds       []int64              `json:"seeds"`
+	Repetitions int                  `json:"repetitions"`
+	Timeout     string               `json:"timeout"`         // per run e.g. "10s", runs are stopped early once all nodes returned
+	Custom      any                  `json:"custom"`          // custom data for every node
+	Template    string               `json:"custom-template"` // generates the custom data instead, see GenerateCustom
+	Schema      any                  `json:"custom-schema"`   // json schema the custom data has to match
+	Checks      bus.CheckConfig      `json:"checks"`
+	Backend     bus.Backend          `json:"backend"`
+	Messages    bus.MessageMode      `json:"messages"`
+	Reliability bus.Reliability      `json:"reliability"`
+	Churn       bus.Churn            `json:"churn"` // the run seed is used unless it has its own
+	Geo         bus.Geo              `json:"geo"`   // likewise, a range replaces the topology
+	Byzantine   []BenchmarkByzantine `json:"byzantine"`
+	Record      string               `json:"record"`       // file to record the events of every run to, see bus.Replay
+	CallTimeout string               `json:"call-timeout"` // how long fCall waits for a response e.g. "1s"
+}
+
+// a byzantine node of every run, whose code behaviour may be read from a file
+type BenchmarkByzantine struct {
+	bus.Byzantine
+	CodePath string `json:"code-path"`
+}
+
+type BenchmarkResult struct {
+	NodeCnt    int           `json:"node-count"`
+	Topology   string        `json:"topology"`
+	Loss       float64       `json:"loss"`
+	Seed       int64         `json:"seed"`
+	Repetition int           `json:"repetition"`
+	WallTime   time.Duration `json:"wall-time"`
+	CPUTime    time.Duration `json:"cpu-time"`
+	Allocs     uint64        `json:"allocs"`
+	AllocBytes uint64        `json:"alloc-bytes"`
+	Messages   int           `json:"messages"`
+	Bytes      int           `json:"bytes"`
+	Lost       int           `json:"lost"`
+	Rounds     int           `json:"rounds"`
+	TimedOut   bool          `json:"timed-out"` // not all nodes returned before the timeout
+	Passed     bool          `json:"passed"`
+	Verdict    string        `json:"verdict"`
+}
+
+func LoadBenchmarkConfig(path string) (BenchmarkConfig, error) {
+	var config BenchmarkConfig
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(b, &config)
+	return config, err
+}
+
+// RunBenchmark runs the users code for every configuration of the grid without
+// the gui
+func RunBenchmark(config BenchmarkConfig) ([]BenchmarkResult, error) {
+	// external programs do not require code, but it may define the checks
+	var code Code
+	if config.CodePath != "" || config.Backend.Kind != bus.ExternalBackend {
+		b, err := os.ReadFile(config.CodePath)
+		if err != nil {
+			return nil, err
+		}
+		code = Code(b)
+	}
+
+	// compiled once for all runs
+	progs, builds := newPrograms(), newNatives()
+	defer builds.remove()
+	switch config.Backend.Kind {
+	case bus.ExternalBackend:
+		if strings.TrimSpace(config.Backend.Command) == "" {
+			return nil, errors.New("the external backend requires a command")
+		}
+	case bus.NativeBackend:
+		if _, err := builds.get(code); err != nil {
+			return nil, err
+		}
+	default:
+		if err := progs.get(code).err; err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := 10 * time.Second
+	if config.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(config.NodeCounts) == 0 {
+		config.NodeCounts = []int{initialNodeCnt}
+	}
+	if len(config.Topologies) == 0 {
+		config.Topologies = []string{"none"}
+	}
+	if len(config.Losses) == 0 {
+		config.Losses = []float64{0}
+	}
+	if len(config.Seeds) == 0 {
+		config.Seeds = []int64{1}
+	}
+	if config.Repetitions < 1 {
+		config.Repetitions = 1
+	}
+
+	var rec *bus.Recording
+	if config.Record != "" {
+		f, err := os.Create(config.Record)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		rec = bus.NewRecording(f)
+	}
+
+	var results []BenchmarkResult
+	for _, cnt := range config.NodeCounts {
+		for _, topology := range config.Topologies {
+			for _, loss := range config.Losses {
+				for _, seed := range config.Seeds {
+					for rep := 0; rep < config.Repetitions; rep++ {
+						log.Info("Benchmark ", cnt, " nodes, ", topology, ", loss ", loss, ", seed ", seed, ", repetition ", rep)
+						res := BenchmarkResult{NodeCnt: cnt, Topology: topology, Loss: loss, Seed: seed, Repetition: rep}
+						err := runBenchmarkOnce(code, progs, builds, rec, config, timeout, &res)
+						if err != nil {
+							return results, err
+						}
+						results = append(results, res)
+					}
+				}
+			}
+		}
+	}
+
+	if rec != nil {
+		if err := rec.Err(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// sets up a fresh network for a single configuration and runs it once
+func runBenchmarkOnce(code Code, progs *programs, builds *natives, rec *bus.Recording, config BenchmarkConfig, timeout time.Duration, res *BenchmarkResult) error {
+	eb := bus.NewEventbus()
+	if rec != nil {
+		defer rec.Record(eb).Unsubscribe()
+	}
+	eb.AwaitPublish(bus.Event{Type: bus.CodeChangeEvt, Data: code})
+	newChecker().Init(eb)
+
+	// a run is done once no online node is running its code anymore
+	var runningMu sync.Mutex
+	running := make(map[int]bool)
+	for i := 0; i < res.NodeCnt; i++ {
+		running[i] = true
+	}
+	changed := make(chan any, 1)
+	update := func(id int, isRunning bool) {
+		runningMu.Lock()
+		if isRunning {
+			running[id] = true
+		} else {
+			delete(running, id)
+		}
+		runningMu.Unlock()
+
+		select {
+		case changed <- nil:
+		default:
+		}
+	}
+	eb.AwaitBind(bus.NodeFinishedEvt, func(id bus.NodeId) {
+		update(int(id), false)
+	})
+	eb.AwaitBind(bus.NodeStateEvt, func(state bus.NodeState) {
+		update(state.NodeId, state.Online)
+	})
+	verdicts := make(chan bus.Verdict, 1)
+	eb.AwaitBind(bus.RunVerdictEvt, func(verdict bus.Verdict) {
+		verdicts <- verdict
+	})
+
+	env := newNetEnv()
+	env.setCode(code)
+	env.programs = progs
+	env.natives = builds
+	env.links.configure(bus.LinkConfig{Loss: res.Loss, Seed: res.Seed})
+	if config.Backend.Kind != "" {
+		env.setBackend(config.Backend)
+	}
+	if config.Messages.Kind != "" {
+		env.setMessageMode(config.Messages)
+	}
+	env.reliabilities.configure(config.Reliability)
+	if config.CallTimeout != "" {
+		callTimeout, err := time.ParseDuration(config.CallTimeout)
+		if err != nil {
+			return err
+		}
+		env.setCallTimeout(callTimeout)
+	}
+	geo := config.Geo
+	if geo.Seed == 0 {
+		geo.Seed = res.Seed
+	}
+	if err := validateGeo(geo); err != nil {
+		return err
+	}
+	env.geo.configure(geo)
+
+	n := newNetwork(res.NodeCnt, env)
+	churn := config.Churn
+	if churn.Seed == 0 {
+		churn.Seed = res.Seed
+	}
+	plan, err := newChurnPlan(churn)
+	if err != nil {
+		return err
+	}
+	n.churn = plan
+	n.setAndRunNodes(eb)
+	// stops the nodes and the worker processes of the run
+	defer n.emit(TERM)
+
+	rng := rand.New(rand.NewSource(res.Seed))
+	connections, err := Topology(res.Topology, res.NodeCnt, rng)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	for _, c := range connections {
+		n.connectNodes(c.From, c.To)
+	}
+	n.mu.Unlock()
+
+	custom := make([]any, res.NodeCnt)
+	for i := range custom {
+		custom[i] = config.Custom
+	}
+	if config.Template != "" {
+		custom, err = GenerateCustom(config.Template, res.NodeCnt, res.Seed)
+		if err != nil {
+			return err
+		}
+	}
+	if err := validateCustom(config.Schema, custom); err != nil {
+		return err
+	}
+
+	resizeData := bus.NetworkResize{Connections: connections, Cnt: res.NodeCnt}
+	eb.AwaitPublish(bus.Event{Type: bus.NetworkResizeEvt, Data: resizeData})
+	for i, c := range custom {
+		n.setData(c, i)
+		data := bus.NodeData{TargetId: i, Data: c}
+		eb.AwaitPublish(bus.Event{Type: bus.NodeDataChangeEvt, Data: data})
+	}
+	n.mu.Lock()
+	n.placeNodes(eb)
+	n.mu.Unlock()
+	eb.AwaitPublish(bus.Event{Type: bus.CheckConfigChangeEvt, Data: config.Checks})
+
+	for _, bb := range config.Byzantine {
+		b := bb.Byzantine
+		if bb.CodePath != "" {
+			code, err := os.ReadFile(bb.CodePath)
+			if err != nil {
+				return err
+			}
+			b.Code = string(code)
+		}
+		if b.Seed == 0 {
+			b.Seed = res.Seed
+		}
+		// nodes beyond the node count of a run stay out of it
+		if b.NodeId >= res.NodeCnt {
+			continue
+		}
+		if err := n.setByzantine(b); err != nil {
+			return err
+		}
+		eb.AwaitPublish(bus.Event{Type: bus.ByzantineChangeEvt, Data: b})
+	}
+
+	// measure the run, including the interpreters every node compiles at its
+	// start
+	var memBefore, memAfter runtime.MemStats
+	runtime.ReadMemStats(&memBefore)
+	cpuBefore := processCPUTime()
+	start := time.Now()
+
+	n.env.metrics.reset()
+	eb.AwaitPublish(bus.Event{Type: bus.StartNodesEvt, Data: nil})
+	n.start(eb, START)
+
+	deadline := time.After(timeout)
+	for !res.TimedOut {
+		runningMu.Lock()
+		done := len(running) == 0
+		runningMu.Unlock()
+		if done {
+			break
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			res.TimedOut = true
+		}
+	}
+	n.stop()
+
+	select {
+	case verdict := <-verdicts:
+		res.Passed = verdict.Passed
+		res.Verdict = summarizeVerdict(verdict)
+	case <-time.After(stopTimeout):
+		res.Verdict = "nodes did not stop"
+	}
+
+	res.WallTime = time.Since(start)
+	res.CPUTime = processCPUTime() - cpuBefore
+	runtime.ReadMemStats(&memAfter)
+	res.Allocs = memAfter.Mallocs - memBefore.Mallocs
+	res.AllocBytes = memAfter.TotalAlloc - memBefore.TotalAlloc
+
+	m := n.env.metrics.snapshot(true)
+	res.Messages = m.Messages
+	res.Bytes = m.Bytes
+	res.Rounds = m.Rounds
+	for _, e := range m.Edges {
+		res.Lost += e.Lost
+	}
+
+	return nil
+}
+
+func summarizeVerdict(verdict bus.Verdict) string {
+	var summary []string
+	for _, c := range verdict.Checks {
+		if c.Passed {
+			summary = append(summary, c.Name+" passed")
+		} else {
+			summary = append(summary, c.Name+" failed : "+c.Reason)
+		}
+	}
+	return strings.Join(summary, "; ")
+}
+
+// WriteBenchmarkReport writes the results as json or csv depending on the
+// files extension
+func WriteBenchmarkReport(path string, results []BenchmarkResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch filepath.Ext(path) {
+	case ".json":
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case ".csv":
+		return writeBenchmarkCSV(f, results)
+	}
+
+	return errors.New("unsupported report format " + filepath.Ext(path))
+}
+
+func writeBenchmarkCSV(w io.Writer, results []BenchmarkResult) error {
+	cw := csv.NewWriter(w)
+	header := []string{"node-count", "topology", "loss", "seed", "repetition",
+		"wall-time-ms", "cpu-time-ms", "allocs", "alloc-bytes", "messages",
+		"bytes", "lost", "rounds", "timed-out", "passed", "verdict"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	ms := func(d time.Duration) string {
+		return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+	}
+	for _, r := range results {
+		record := []string{
+			strconv.Itoa(r.NodeCnt),
+			r.Topology,
+			strconv.FormatFloat(r.Loss, 'f', -1, 64),
+			strconv.FormatInt(r.Seed, 10),
+			strconv.Itoa(r.Repetition),
+			ms(r.WallTime),
+			ms(r.CPUTime),
+			strconv.FormatUint(r.Allocs, 10),
+			strconv.FormatUint(r.AllocBytes, 10),
+			strconv.Itoa(r.Messages),
+			strconv.Itoa(r.Bytes),
+			strconv.Itoa(r.Lost),
+			strconv.Itoa(r.Rounds),
+			strconv.FormatBool(r.TimedOut),
+			strconv.FormatBool(r.Passed),
+			r.Verdict,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
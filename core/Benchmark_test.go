@@ -0,0 +1,138 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const benchmarkTestCode = `package main
+
+import (
+	"context"
+	"distributed-sys-emulator/sim"
+)
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	for _, to := range sim.OutNeighbors(ctx) {
+		sim.Send(ctx, to, sim.ID(ctx))
+	}
+	return 1
+}
+`
+
+var benchmarkTestResults = []BenchmarkResult{
+	{NodeCnt: 2, Topology: "ring", Loss: 0.25, Seed: 3, Repetition: 1, WallTime: 1500 * time.Microsecond,
+		CPUTime: 2 * time.Millisecond, Allocs: 10, AllocBytes: 2048, Messages: 4, Bytes: 64, Lost: 1,
+		Rounds: 2, Passed: true, Verdict: "agreement passed"},
+	{NodeCnt: 3, Topology: "random:0.5", TimedOut: true, Verdict: "agreement failed : a, b"},
+}
+
+func TestWriteBenchmarkReport_CSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := WriteBenchmarkReport(path, benchmarkTestResults); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"node-count", "topology", "loss", "seed", "repetition", "wall-time-ms", "cpu-time-ms", "allocs",
+			"alloc-bytes", "messages", "bytes", "lost", "rounds", "timed-out", "passed", "verdict"},
+		{"2", "ring", "0.25", "3", "1", "1.500", "2.000", "10", "2048", "4", "64", "1", "2", "false", "true", "agreement passed"},
+		{"3", "random:0.5", "0", "0", "0", "0.000", "0.000", "0", "0", "0", "0", "0", "0", "true", "false", "agreement failed : a, b"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("Expected %v, got %v", want, records)
+	}
+}
+
+func TestWriteBenchmarkReport_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := WriteBenchmarkReport(path, benchmarkTestResults); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report []map[string]any
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(report))
+	}
+	keys := []string{"node-count", "topology", "loss", "seed", "repetition", "wall-time", "cpu-time", "allocs",
+		"alloc-bytes", "messages", "bytes", "lost", "rounds", "timed-out", "passed", "verdict"}
+	for _, key := range keys {
+		if _, ok := report[0][key]; !ok {
+			t.Errorf("Expected the key %s in %v", key, report[0])
+		}
+	}
+	if len(report[0]) != len(keys) {
+		t.Errorf("Expected %d keys, got %v", len(keys), report[0])
+	}
+	if report[0]["topology"] != "ring" || report[0]["wall-time"] != 1.5e6 || report[1]["timed-out"] != true {
+		t.Errorf("Unexpected results %v", report)
+	}
+
+	if err := WriteBenchmarkReport(filepath.Join(t.TempDir(), "report.txt"), nil); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestRunBenchmark_Grid(t *testing.T) {
+	codePath := filepath.Join(t.TempDir(), "code.go")
+	if err := os.WriteFile(codePath, []byte(benchmarkTestCode), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RunBenchmark(BenchmarkConfig{
+		CodePath:   codePath,
+		NodeCounts: []int{2, 3},
+		Topologies: []string{"none", "ring"},
+		Timeout:    "10s",
+		Checks:     bus.CheckConfig{Agreement: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("Expected a result for each of the 4 cells, got %d", len(results))
+	}
+	cells := []struct {
+		cnt      int
+		topology string
+		messages int
+	}{{2, "none", 0}, {2, "ring", 2}, {3, "none", 0}, {3, "ring", 3}}
+	for i, cell := range cells {
+		r := results[i]
+		if r.NodeCnt != cell.cnt || r.Topology != cell.topology {
+			t.Errorf("Expected cell %d to be %d nodes on %s, got %d nodes on %s", i, cell.cnt, cell.topology, r.NodeCnt, r.Topology)
+		}
+		if r.TimedOut || !r.Passed || !strings.Contains(r.Verdict, "passed") {
+			t.Errorf("Expected cell %d to pass, got %+v", i, r)
+		}
+		if r.Messages != cell.messages {
+			t.Errorf("Expected %d messages in cell %d, got %d", cell.messages, i, r.Messages)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* Byzantine nodes deviate from the protocol in what they send, so that
+* protocols tolerating them can be tested. The behaviour applies to the messages
+* sent with fSend, calls are left alone. The results of byzantine nodes are not
+* part of the verdict of the result checks, nor is their custom data proposed.
+ */
+
+func validateByzantine(b bus.Byzantine) error {
+	switch b.Kind {
+	case "", bus.Honest, bus.DropByzantine, bus.EquivocateByzantine, bus.ReplayByzantine, bus.CorruptByzantine:
+	case bus.CodeByzantine:
+		if strings.TrimSpace(b.Code) == "" {
+			return errors.New("the code behaviour requires code")
+		}
+	default:
+		return fmt.Errorf("unknown byzantine behaviour %q", b.Kind)
+	}
+	return nil
+}
+
+func isByzantine(b bus.Byzantine) bool {
+	return b.Kind != "" && b.Kind != bus.Honest
+}
+
+// applies the behaviour of a byzantine node to its messages during a run
+type adversary struct {
+	kind bus.ByzantineKind
+
+	mu   sync.Mutex
+	rng  *rand.Rand
+	sent []any // replay only
+}
+
+// nil for honest nodes
+func newAdversary(b bus.Byzantine) *adversary {
+	if !isByzantine(b) {
+		return nil
+	}
+	seed := b.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &adversary{kind: b.Kind, rng: rand.New(rand.NewSource(seed))}
+}
+
+// what is sent to a peer instead of data, false if nothing is sent
+func (a *adversary) tamper(to int, data any) (any, bool) {
+	if a == nil {
+		return data, true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch a.kind {
+	case bus.DropByzantine:
+		return nil, false
+	case bus.EquivocateByzantine:
+		if to%2 == 1 {
+			return corrupt(data, a.rng), true
+		}
+	case bus.ReplayByzantine:
+		a.sent = append(a.sent, data)
+		if len(a.sent) > 1 {
+			return a.sent[a.rng.Intn(len(a.sent)-1)], true
+		}
+	case bus.CorruptByzantine:
+		return corrupt(data, a.rng), true
+	}
+	return data, true
+}
+
+// returns a value of the same type that differs from data, numbers are
+// shifted, booleans negated, strings extended and containers corrupted element
+// wise. nil becomes a random int.
+func corrupt(data any, rng *rand.Rand) any {
+	if data == nil {
+		return rng.Intn(1000)
+	}
+	src := reflect.ValueOf(data)
+	dst := reflect.New(src.Type()).Elem()
+	corruptValue(dst, src, rng)
+	return dst.Interface()
+}
+
+func corruptValue(dst, src reflect.Value, rng *rand.Rand) {
+	switch src.Kind() {
+	case reflect.Bool:
+		dst.SetBool(!src.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dst.SetInt(src.Int() + 1 + rng.Int63n(100))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		dst.SetUint(src.Uint() + 1 + uint64(rng.Intn(100)))
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(src.Float() + 1 + 100*rng.Float64())
+	case reflect.String:
+		dst.SetString(src.String() + "~" + strconv.FormatInt(rng.Int63(), 36))
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			corruptValue(dst.Index(i), src.Index(i), rng)
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			corruptValue(dst.Index(i), src.Index(i), rng)
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		iter := src.MapRange()
+		for iter.Next() {
+			v := reflect.New(src.Type().Elem()).Elem()
+			corruptValue(v, iter.Value(), rng)
+			dst.SetMapIndex(iter.Key(), v)
+		}
+	case reflect.Struct:
+		// unexported fields keep their value
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				corruptValue(dst.Field(i), src.Field(i), rng)
+			}
+		}
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Type().Elem()))
+		corruptValue(dst.Elem(), src.Elem(), rng)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.ValueOf(corrupt(src.Elem().Interface(), rng)))
+	default:
+		dst.Set(src)
+	}
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestCorrupt(t *testing.T) {
+	type point struct {
+		X, Y int
+		tag  string
+	}
+	rng := rand.New(rand.NewSource(1))
+	values := []any{
+		1, 1.5, true, "foo", []int{1, 2},
+		map[string]any{"value": 3.0},
+		point{X: 1, Y: 2, tag: "a"},
+		&point{X: 1},
+	}
+	for _, v := range values {
+		c := corrupt(v, rng)
+		if reflect.TypeOf(c) != reflect.TypeOf(v) {
+			t.Errorf("Expected %T, got %T", v, c)
+		}
+		if reflect.DeepEqual(c, v) {
+			t.Errorf("Expected %v to be corrupted", v)
+		}
+	}
+
+	// unexported fields keep their value
+	if p := corrupt(point{tag: "a"}, rng).(point); p.tag != "a" {
+		t.Errorf("Expected the unexported field to stay, got %v", p)
+	}
+}
+
+func TestAdversary_Tamper(t *testing.T) {
+	var honest *adversary
+	if data, ok := honest.tamper(1, 5); !ok || data != 5 {
+		t.Errorf("Expected honest nodes to send the data, got %v %v", data, ok)
+	}
+
+	drop := newAdversary(bus.Byzantine{Kind: bus.DropByzantine, Seed: 1})
+	if _, ok := drop.tamper(1, 5); ok {
+		t.Error("Expected nothing to be sent")
+	}
+
+	equivocate := newAdversary(bus.Byzantine{Kind: bus.EquivocateByzantine, Seed: 1})
+	if data, _ := equivocate.tamper(2, 5); data != 5 {
+		t.Errorf("Expected even peers to receive the data, got %v", data)
+	}
+	if data, _ := equivocate.tamper(3, 5); data == 5 {
+		t.Error("Expected odd peers to receive corrupted data")
+	}
+
+	replay := newAdversary(bus.Byzantine{Kind: bus.ReplayByzantine, Seed: 1})
+	if data, _ := replay.tamper(1, "first"); data != "first" {
+		t.Errorf("Expected the first message to be sent, got %v", data)
+	}
+	for _, next := range []string{"second", "third"} {
+		if data, _ := replay.tamper(1, next); data == next {
+			t.Errorf("Expected an earlier message instead of %v", next)
+		}
+	}
+
+	if err := validateByzantine(bus.Byzantine{Kind: bus.CodeByzantine}); err == nil {
+		t.Error("Expected the code behaviour to require code")
+	}
+	if err := validateByzantine(bus.Byzantine{Kind: "lie"}); err == nil {
+		t.Error("Expected an error for an unknown behaviour")
+	}
+}
+
+func TestNetwork_Byzantine(t *testing.T) {
+	eb := bus.NewEventbus()
+	n := newNetwork(3, newNetEnv())
+	n.env.setCode(Code(topologyTestCode))
+	n.setAndRunNodes(eb)
+	t.Cleanup(func() { n.emit(TERM) })
+
+	// node 0 corrupts what it sends, node 2 runs its own code
+	if err := n.setByzantine(bus.Byzantine{NodeId: 0, Kind: bus.CorruptByzantine, Seed: 1}); err != nil {
+		t.Fatal(err)
+	}
+	evil := `package main
+
+import "context"
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	return "evil"
+}
+`
+	if err := n.setByzantine(bus.Byzantine{NodeId: 2, Kind: bus.CodeByzantine, Code: evil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.setByzantine(bus.Byzantine{NodeId: 5, Kind: bus.DropByzantine}); err == nil {
+		t.Error("Expected an error for a missing node")
+	}
+
+	n.mu.Lock()
+	n.connectNodes(0, 1)
+	n.mu.Unlock()
+
+	results := make(chan bus.NodeOutput, 3)
+	eb.AwaitBind(bus.NodeOutputEvt, func(out bus.NodeOutput) {
+		results <- out
+	})
+	finished := make(chan bus.NodeId, 3)
+	eb.AwaitBind(bus.NodeFinishedEvt, func(id bus.NodeId) {
+		finished <- id
+	})
+
+	n.emit(START)
+	for i := 0; i < 3; i++ {
+		awaitFinished(t, finished)
+	}
+	n.emit(STOP)
+
+	for i := 0; i < 3; i++ {
+		out := <-results
+		switch out.NodeId {
+		case 1:
+			if out.Result == "hello" {
+				t.Error("Expected node 1 to receive a corrupted message")
+			}
+		case 2:
+			if out.Result != "evil" {
+				t.Errorf("Expected node 2 to run its own code, got %v %s", out.Result, out.Log)
+			}
+		}
+	}
+}
+
+func TestChecker_Excludes_Byzantine(t *testing.T) {
+	eb := bus.NewEventbus()
+	c := newChecker()
+	c.Init(eb)
+
+	verdicts := make(chan bus.Verdict, 1)
+	eb.AwaitBind(bus.RunVerdictEvt, func(v bus.Verdict) {
+		verdicts <- v
+	})
+
+	eb.AwaitPublish(bus.Event{Type: bus.NetworkResizeEvt, Data: bus.NetworkResize{Cnt: 3}})
+	eb.AwaitPublish(bus.Event{Type: bus.CheckConfigChangeEvt, Data: bus.CheckConfig{Agreement: true}})
+	eb.AwaitPublish(bus.Event{Type: bus.ByzantineChangeEvt, Data: bus.Byzantine{NodeId: 2, Kind: bus.EquivocateByzantine}})
+	eb.AwaitPublish(bus.Event{Type: bus.StartNodesEvt})
+	for i, r := range []any{1, 1, 2} {
+		eb.AwaitPublish(bus.Event{Type: bus.NodeOutputEvt, Data: bus.NodeOutput{NodeId: i, Result: r}})
+	}
+
+	if v := <-verdicts; !v.Passed {
+		t.Errorf("Expected the honest nodes to agree, got %v", v)
+	}
+}
+
+func TestChecker_Byzantine_Node_Ids(t *testing.T) {
+	eb, run := startCheckerTest(t, 3, bus.CheckConfig{Agreement: true})
+	bus.ByzantineChangeTopic.AwaitPublish(eb, bus.Byzantine{NodeId: 0, Kind: bus.EquivocateByzantine})
+
+	// the honest nodes are named by their id, not their index among them
+	v := run(map[int]any{0: 3, 1: 1, 2: 2})
+	expected := "node 1 returned 1 but node 2 returned 2"
+	if v.Passed || len(v.Checks) != 1 || v.Checks[0].Reason != expected {
+		t.Errorf("Expected the reason %q, got %v", expected, v)
+	}
+}
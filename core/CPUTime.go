@@ -0,0 +1,17 @@
+//go:build unix
+
+package core
+
+import (
+	"syscall"
+	"time"
+)
+
+// cpu time (user and system) consumed by this process so far
+func processCPUTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package core
+
+import "time"
+
+// cpu time is not measured on this platform
+func processCPUTime() time.Duration {
+	return 0
+}
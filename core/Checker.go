@@ -0,0 +1,263 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/check"
+	"distributed-sys-emulator/log"
+	"fmt"
+	"sync"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+// user code may define functions with these names and signatures to customize
+// the checks run over the node results
+type equalFunc = func(a, b any) bool
+type checkFunc = func(results []any, proposals []any) error
+
+// collects the results of all nodes for a run and publishes a verdict once
+// every node has reported
+type checker struct {
+	mu        sync.Mutex
+	config    bus.CheckConfig
+	code      Code
+	custom    []any
+	results   []any
+	reported  []bool
+	offline   []bool // nodes that left are not part of the verdict
+	byzantine []bool // nor are byzantine nodes
+	pending   bool   // a verdict is due once every node reported
+
+	// the checks defined by the code, interpreted once it changed
+	userLoaded bool
+	userEqual  check.Comparator
+	userCheck  checkFunc
+}
+
+func newChecker() *checker {
+	return &checker{}
+}
+
+// binds synchronously so the checker is ready once Init returns
+func (c *checker) Init(eb bus.EventBus) {
+	bus.CheckConfigChangeTopic.AwaitSubscribe(eb, func(config bus.CheckConfig) {
+		c.mu.Lock()
+		c.config = config
+		c.mu.Unlock()
+	})
+
+	bus.CodeChangeTopic.AwaitSubscribe(eb, func(code Code) {
+		c.mu.Lock()
+		c.code = code
+		c.userLoaded = false
+		c.mu.Unlock()
+	})
+
+	bus.NodeDataChangeTopic.AwaitSubscribe(eb, func(data bus.NodeData) {
+		c.mu.Lock()
+		if data.TargetId < len(c.custom) {
+			c.custom[data.TargetId] = data.Data
+		}
+		c.mu.Unlock()
+	})
+
+	// remaining nodes keep their custom data and results on resize
+	bus.NetworkResizeTopic.AwaitSubscribe(eb, func(resizeData bus.NetworkResize) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		cnt := resizeData.Cnt
+		c.custom = resized(c.custom, cnt)
+		c.results = resized(c.results, cnt)
+		c.reported = resized(c.reported, cnt)
+		c.offline = resized(c.offline, cnt)
+		c.byzantine = resized(c.byzantine, cnt)
+		c.publishIfComplete(eb)
+	})
+
+	bus.NodeStateTopic.AwaitSubscribe(eb, func(state bus.NodeState) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if state.NodeId >= len(c.offline) {
+			return
+		}
+		c.offline[state.NodeId] = !state.Online
+		c.publishIfComplete(eb)
+	})
+
+	bus.ByzantineChangeTopic.AwaitSubscribe(eb, func(b bus.Byzantine) {
+		c.mu.Lock()
+		if b.NodeId >= 0 && b.NodeId < len(c.byzantine) {
+			c.byzantine[b.NodeId] = isByzantine(b) && validateByzantine(b) == nil
+		}
+		c.mu.Unlock()
+	})
+
+	bus.StartNodesTopic.AwaitSubscribe(eb, func() {
+		c.mu.Lock()
+		c.reset(len(c.custom))
+		c.mu.Unlock()
+	})
+
+	bus.DebugNodesTopic.AwaitSubscribe(eb, func() {
+		c.mu.Lock()
+		c.reset(len(c.custom))
+		c.mu.Unlock()
+	})
+
+	bus.NodeOutputTopic.AwaitSubscribe(eb, func(out bus.NodeOutput) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if out.NodeId >= len(c.results) || c.reported[out.NodeId] {
+			return
+		}
+		c.results[out.NodeId] = out.Result
+		c.reported[out.NodeId] = true
+		c.publishIfComplete(eb)
+	})
+}
+
+func (c *checker) reset(cnt int) {
+	c.results = make([]any, cnt)
+	c.reported = make([]bool, cnt)
+	c.pending = true
+}
+
+// publishes the verdict of the run once every node reported, requires the
+// lock to be held
+func (c *checker) publishIfComplete(eb bus.EventBus) {
+	if !c.pending {
+		return
+	}
+	for i, r := range c.reported {
+		if !r && !c.offline[i] {
+			return
+		}
+	}
+
+	c.pending = false
+	verdict := c.verify()
+	bus.RunVerdictTopic.Publish(eb, verdict)
+}
+
+// keeps the first cnt elements, appending zero values as required
+func resized[T any](s []T, cnt int) []T {
+	if cnt <= len(s) {
+		return s[:cnt]
+	}
+	return append(s, make([]T, cnt-len(s))...)
+}
+
+// run all configured checks over the collected results
+func (c *checker) verify() bus.Verdict {
+	eq, custom := c.loadUserChecks()
+
+	var checks []check.Check
+	if c.config.Agreement {
+		checks = append(checks, check.Agreement(eq))
+	}
+	if c.config.Validity {
+		checks = append(checks, check.Validity(eq))
+	}
+	if c.config.Uniqueness {
+		checks = append(checks, check.Uniqueness(c.config.UniquenessMarker, eq))
+	}
+	if custom != nil {
+		checks = append(checks, check.Custom("custom", custom))
+	}
+
+	// only the honest nodes that are online take part, the reasons refer to
+	// them by their id
+	var ids []int
+	var results, data []any
+	for i := range c.results {
+		if !c.offline[i] && !c.byzantine[i] {
+			ids = append(ids, i)
+			results = append(results, c.results[i])
+			data = append(data, c.custom[i])
+		}
+	}
+
+	verdict := bus.Verdict{Passed: true}
+	proposals, err := check.Proposals(ids, data, c.config.ValidityKey)
+	for _, chk := range checks {
+		var checkErr error
+		if chk.NeedsProposals() && err != nil {
+			checkErr = err
+		} else {
+			checkErr = verifySafely(chk, ids, results, proposals)
+		}
+
+		res := bus.CheckResult{Name: chk.Name(), Passed: checkErr == nil}
+		if checkErr != nil {
+			res.Reason = checkErr.Error()
+			verdict.Passed = false
+		}
+		verdict.Checks = append(verdict.Checks, res)
+	}
+
+	return verdict
+}
+
+// the check fails if it panics, e.g. in the Equal or Check function of the
+// user code
+func verifySafely(chk check.Check, ids []int, results, proposals []any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic : %v", r)
+		}
+	}()
+	return chk.Verify(ids, results, proposals)
+}
+
+// the optional Equal and Check functions of the user code, interpreted only
+// for the first verdict after the code changed, requires the lock to be held
+func (c *checker) loadUserChecks() (check.Comparator, checkFunc) {
+	if !c.userLoaded {
+		c.userEqual, c.userCheck = interpretUserChecks(c.code)
+		c.userLoaded = true
+	}
+	return c.userEqual, c.userCheck
+}
+
+// looks up the optional Equal and Check functions in the code, falling back to
+// the default comparator
+func interpretUserChecks(code Code) (eq check.Comparator, custom checkFunc) {
+	eq = check.Equal
+
+	// package level initializers run here
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error(fmt.Errorf("panic : %v", r), " interpreting the checks")
+			eq, custom = check.Equal, nil
+		}
+	}()
+
+	// the code may import sim like the code run by the nodes
+	i := interp.New(interp.Options{GoPath: ".", SourcecodeFilesystem: sources})
+	if err := i.Use(stdlib.Symbols); err != nil {
+		log.Error(err)
+		return eq, nil
+	}
+
+	if _, err := i.Eval(string(code)); err != nil {
+		return eq, nil
+	}
+
+	if v, err := i.Eval("Equal"); err == nil {
+		if f, ok := v.Interface().(equalFunc); ok {
+			eq = f
+		}
+	}
+
+	if v, err := i.Eval("Check"); err == nil {
+		if f, ok := v.Interface().(checkFunc); ok {
+			custom = f
+		}
+	}
+
+	return eq, custom
+}
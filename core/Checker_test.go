@@ -0,0 +1,114 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"strings"
+	"testing"
+	"time"
+)
+
+// every result is equal to every other one
+const lenientCode = `package main
+
+import (
+	"context"
+	"distributed-sys-emulator/sim"
+)
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	return sim.ID(ctx)
+}
+
+func Equal(a, b any) bool {
+	return true
+}
+`
+
+// a checker of cnt nodes, the returned function starts a run in which the
+// nodes return the results and waits for its verdict
+func startCheckerTest(t *testing.T, cnt int, config bus.CheckConfig) (bus.EventBus, func(results map[int]any) bus.Verdict) {
+	eb := bus.NewEventbus()
+	verdicts := make(chan bus.Verdict, 1)
+	bus.RunVerdictTopic.AwaitSubscribe(eb, func(v bus.Verdict) {
+		verdicts <- v
+	})
+
+	c := newChecker()
+	c.Init(eb)
+	bus.NetworkResizeTopic.AwaitPublish(eb, bus.NetworkResize{Cnt: cnt})
+	bus.CheckConfigChangeTopic.AwaitPublish(eb, config)
+
+	return eb, func(results map[int]any) bus.Verdict {
+		bus.StartNodesTopic.AwaitPublish(eb)
+		for id, res := range results {
+			bus.NodeOutputTopic.AwaitPublish(eb, bus.NodeOutput{NodeId: id, Result: res})
+		}
+		select {
+		case v := <-verdicts:
+			return v
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected a verdict once all nodes reported")
+			return bus.Verdict{}
+		}
+	}
+}
+
+func TestChecker_User_Equal(t *testing.T) {
+	eb, run := startCheckerTest(t, 2, bus.CheckConfig{Agreement: true})
+	verdict := func() bus.Verdict {
+		return run(map[int]any{0: 1, 1: 2})
+	}
+
+	bus.CodeChangeTopic.AwaitPublish(eb, lenientCode)
+	if v := verdict(); !v.Passed {
+		t.Errorf("Expected the user comparator to let the results agree, got %v", v)
+	}
+	if v := verdict(); !v.Passed {
+		t.Errorf("Expected the user comparator to be kept for the next run, got %v", v)
+	}
+
+	// the checks are interpreted again once the code changed
+	bus.CodeChangeTopic.AwaitPublish(eb, "package main\n")
+	if v := verdict(); v.Passed {
+		t.Errorf("Expected the default comparator to tell the results apart, got %v", v)
+	}
+}
+
+func TestChecker_Offline_Node_Ids(t *testing.T) {
+	eb, run := startCheckerTest(t, 3, bus.CheckConfig{Agreement: true})
+	bus.NodeStateTopic.AwaitPublish(eb, bus.NodeState{NodeId: 1, Online: false})
+
+	v := run(map[int]any{0: 1, 2: 2})
+	expected := "node 0 returned 1 but node 2 returned 2"
+	if v.Passed || len(v.Checks) != 1 || v.Checks[0].Reason != expected {
+		t.Errorf("Expected the reason %q, got %v", expected, v)
+	}
+}
+
+// the custom check fails on a bad type assertion
+const panickingCheckCode = `package main
+
+func Check(results []any, proposals []any) error {
+	_ = results[0].(string)
+	return nil
+}
+`
+
+func TestChecker_User_Check_Panics(t *testing.T) {
+	eb, run := startCheckerTest(t, 2, bus.CheckConfig{Agreement: true})
+	bus.CodeChangeTopic.AwaitPublish(eb, panickingCheckCode)
+
+	// the checker keeps publishing verdicts
+	for i := 0; i < 2; i++ {
+		v := run(map[int]any{0: 1, 1: 1})
+		if v.Passed || len(v.Checks) != 2 {
+			t.Fatalf("Expected the custom check to fail, got %v", v)
+		}
+		if custom := v.Checks[1]; custom.Passed || !strings.HasPrefix(custom.Reason, "panic : ") {
+			t.Errorf("Expected the panic to be reported, got %v", custom)
+		}
+		if !v.Checks[0].Passed {
+			t.Errorf("Expected the other checks to pass, got %v", v.Checks[0])
+		}
+	}
+}
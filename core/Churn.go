@@ -0,0 +1,304 @@
+package core
+
+import (
+	"bufio"
+	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/log"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+/* Churn makes nodes join and leave the network while it runs. A node that
+* leaves stops running its code and loses all of its connections, it keeps its
+* id and may join again later with a fresh state. Joining nodes are connected
+* according to a bootstrap rule.
+*
+* poisson  joins arrive with exponentially distributed gaps (ArrivalRate per
+*          second) and bring a random offline node back, or add a new one if
+*          every node is online. Every online node leaves once its session,
+*          drawn from the session length distribution, has passed.
+* trace    joins and leaves are read from a file, one per line :
+*
+*            # seconds since the start of the run, event, node id
+*            0.5 leave 3
+*            2   join 3
+*            4   join 12
+*
+*          Joins of ids beyond the node count add nodes.
+*
+* Nodes that are offline once a run ends join again at the start of the next.
+ */
+
+const defaultBootstrap = "random:2"
+
+// Bootstrap returns the connections of a joining node to the online nodes for
+// a named rule, connections are bidirectional. Supported are
+//   - none
+//   - all, every online node
+//   - node:id, a fixed bootstrap node, if it is online
+//   - random:k, k random online nodes (default random:2)
+func Bootstrap(rule string, id int, online []int, rng *rand.Rand) (bus.Connections, error) {
+	if rule == "" {
+		rule = defaultBootstrap
+	}
+
+	var peers []int
+	scheme, param, _ := strings.Cut(rule, ":")
+	switch scheme {
+	case "none":
+	case "all":
+		peers = online
+	case "node":
+		peer, err := strconv.Atoi(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bootstrap node : %w", err)
+		}
+		for _, o := range online {
+			if o == peer {
+				peers = []int{peer}
+			}
+		}
+	case "random":
+		k, err := strconv.Atoi(param)
+		if err != nil || k < 0 {
+			return nil, fmt.Errorf("invalid number of random bootstrap nodes %q", param)
+		}
+		perm := rng.Perm(len(online))
+		for i := 0; i < k && i < len(perm); i++ {
+			peers = append(peers, online[perm[i]])
+		}
+	default:
+		return nil, fmt.Errorf("unknown bootstrap rule %q", rule)
+	}
+
+	var res bus.Connections
+	for _, peer := range peers {
+		if peer != id {
+			res = append(res, bus.Connection{From: id, To: peer}, bus.Connection{From: peer, To: id})
+		}
+	}
+	return res, nil
+}
+
+// a join or leave of a trace
+type churnEvent struct {
+	at   time.Duration // since the start of the run
+	id   int
+	join bool
+}
+
+func parseTrace(r io.Reader) ([]churnEvent, error) {
+	var res []churnEvent
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("trace line %d : expected time, event and node id", line)
+		}
+
+		seconds, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("trace line %d : invalid time %q", line, fields[0])
+		}
+		id, err := strconv.Atoi(fields[2])
+		if err != nil || id < 0 {
+			return nil, fmt.Errorf("trace line %d : invalid node id %q", line, fields[2])
+		}
+
+		e := churnEvent{at: time.Duration(seconds * float64(time.Second)), id: id}
+		switch fields[1] {
+		case "join":
+			e.join = true
+		case "leave":
+		default:
+			return nil, fmt.Errorf("trace line %d : unknown event %q", line, fields[1])
+		}
+		res = append(res, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].at < res[j].at
+	})
+	return res, nil
+}
+
+// draws the time a node stays online
+func sessionLength(s bus.Session, rng *rand.Rand) time.Duration {
+	mean := float64(s.Mean)
+	var length float64
+	switch s.Kind {
+	case bus.ParetoSession:
+		// scale such that the mean matches
+		xm := mean * (s.Shape - 1) / s.Shape
+		length = xm / math.Pow(1-rng.Float64(), 1/s.Shape)
+	case bus.WeibullSession:
+		scale := mean / math.Gamma(1+1/s.Shape)
+		length = scale * math.Pow(-math.Log(1-rng.Float64()), 1/s.Shape)
+	case bus.FixedSession:
+		length = mean
+	default:
+		length = rng.ExpFloat64() * mean
+	}
+	return time.Duration(length)
+}
+
+// a validated churn config, nil for no churn
+type churnPlan struct {
+	config bus.Churn
+	trace  []churnEvent
+}
+
+func newChurnPlan(config bus.Churn) (*churnPlan, error) {
+	if _, err := Bootstrap(config.Bootstrap, 0, nil, rand.New(rand.NewSource(0))); err != nil {
+		return nil, err
+	}
+
+	p := &churnPlan{config: config}
+	switch config.Kind {
+	case "", bus.NoChurn:
+		return nil, nil
+	case bus.PoissonChurn:
+		if config.ArrivalRate < 0 {
+			return nil, fmt.Errorf("invalid arrival rate %v", config.ArrivalRate)
+		}
+		s := config.Session
+		if s.Mean <= 0 {
+			return nil, fmt.Errorf("the mean session length has to be positive")
+		}
+		switch s.Kind {
+		case "", bus.ExponentialSession, bus.FixedSession:
+		case bus.ParetoSession:
+			if s.Shape <= 1 {
+				return nil, fmt.Errorf("the shape of a pareto session has to be greater than 1")
+			}
+		case bus.WeibullSession:
+			if s.Shape <= 0 {
+				return nil, fmt.Errorf("the shape of a weibull session has to be positive")
+			}
+		default:
+			return nil, fmt.Errorf("unknown session distribution %q", s.Kind)
+		}
+	case bus.TraceChurn:
+		f, err := os.Open(config.Trace)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if p.trace, err = parseTrace(f); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown churn %q", config.Kind)
+	}
+	return p, nil
+}
+
+func (p *churnPlan) rng() *rand.Rand {
+	seed := p.config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// makes nodes join and leave until ctx is done
+func (p *churnPlan) run(ctx context.Context, eb bus.EventBus, n *network) {
+	rng := p.rng()
+	start := time.Now()
+
+	wait := func(until time.Time) bool {
+		timer := time.NewTimer(time.Until(until))
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		}
+	}
+
+	if p.config.Kind == bus.TraceChurn {
+		for _, e := range p.trace {
+			if !wait(start.Add(e.at)) {
+				return
+			}
+			if e.join {
+				n.join(eb, e.id, p.config.Bootstrap, rng)
+			} else {
+				n.leave(eb, e.id)
+			}
+		}
+		return
+	}
+
+	// poisson arrivals, every node leaves once its session ended
+	leaves := make(map[int]time.Time)
+	for _, id := range n.onlineIds() {
+		leaves[id] = start.Add(sessionLength(p.config.Session, rng))
+	}
+	nextArrival := func(from time.Time) time.Time {
+		if p.config.ArrivalRate <= 0 {
+			return time.Time{}
+		}
+		gap := rng.ExpFloat64() / p.config.ArrivalRate
+		return from.Add(time.Duration(gap * float64(time.Second)))
+	}
+	arrival := nextArrival(start)
+
+	for {
+		next, leaving := arrival, -1
+		for id, at := range leaves {
+			if next.IsZero() || at.Before(next) || (at.Equal(next) && leaving >= 0 && id < leaving) {
+				next, leaving = at, id
+			}
+		}
+		if next.IsZero() {
+			<-ctx.Done()
+			return
+		}
+		if !wait(next) {
+			return
+		}
+
+		if leaving >= 0 {
+			delete(leaves, leaving)
+			n.leave(eb, leaving)
+			continue
+		}
+
+		id := n.arrive(eb, p.config.Bootstrap, rng)
+		leaves[id] = next.Add(sessionLength(p.config.Session, rng))
+		arrival = nextArrival(next)
+	}
+}
+
+// publishes whether the churn config was applied, nil stops the churn
+func (n *network) setChurn(eb bus.EventBus, config bus.Churn) {
+	plan, err := newChurnPlan(config)
+	res := bus.ChurnConfigResult{}
+	if err != nil {
+		log.Error(err)
+		res.Err = err.Error()
+	} else {
+		n.mu.Lock()
+		n.churn = plan
+		n.mu.Unlock()
+	}
+	eb.Publish(bus.Event{Type: bus.ChurnConfigResultEvt, Data: res})
+}
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTrace(t *testing.T) {
+	trace := `# a comment
+2 join 3
+0.5 leave 3 # leaves first
+
+1   join 12
+`
+	events, err := parseTrace(strings.NewReader(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []churnEvent{
+		{at: 500 * time.Millisecond, id: 3, join: false},
+		{at: time.Second, id: 12, join: true},
+		{at: 2 * time.Second, id: 3, join: true},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Expected %v at %d, got %v", expected[i], i, events[i])
+		}
+	}
+
+	for _, invalid := range []string{"1 join", "x join 1", "1 crash 1", "1 join -1"} {
+		if _, err := parseTrace(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	online := []int{0, 1, 2, 3}
+
+	tests := []struct {
+		rule  string
+		peers int
+	}{
+		{"none", 0},
+		{"all", 4},
+		{"node:2", 1},
+		{"node:7", 0},
+		{"random:2", 2},
+		{"", 2},
+		{"random:9", 4},
+	}
+	for _, test := range tests {
+		connections, err := Bootstrap(test.rule, 5, online, rng)
+		if err != nil {
+			t.Errorf("%q : %v", test.rule, err)
+			continue
+		}
+		if len(connections) != 2*test.peers {
+			t.Errorf("%q : expected %d bidirectional connections, got %v", test.rule, test.peers, connections)
+		}
+	}
+
+	// a node does not connect to itself
+	if connections, _ := Bootstrap("all", 1, online, rng); len(connections) != 6 {
+		t.Errorf("Expected 6 connections, got %v", connections)
+	}
+
+	for _, invalid := range []string{"random", "node:x", "ring"} {
+		if _, err := Bootstrap(invalid, 0, online, rng); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestSessionLength_Mean(t *testing.T) {
+	sessions := []bus.Session{
+		{Kind: bus.ExponentialSession, Mean: time.Second},
+		{Kind: bus.ParetoSession, Mean: time.Second, Shape: 3},
+		{Kind: bus.WeibullSession, Mean: time.Second, Shape: 0.5},
+		{Kind: bus.FixedSession, Mean: time.Second},
+	}
+	for _, s := range sessions {
+		rng := rand.New(rand.NewSource(1))
+		var sum time.Duration
+		const cnt = 20000
+		for i := 0; i < cnt; i++ {
+			sum += sessionLength(s, rng)
+		}
+		mean := sum / cnt
+		if mean < 900*time.Millisecond || mean > 1100*time.Millisecond {
+			t.Errorf("%s : expected a mean of about 1s, got %v", s.Kind, mean)
+		}
+	}
+}
+
+func TestNetwork_Leave_Join(t *testing.T) {
+	n, eb, _ := startTopologyTest(t, 3)
+
+	states := make(chan bus.NodeState, 10)
+	eb.AwaitBind(bus.NodeStateEvt, func(state bus.NodeState) {
+		states <- state
+	})
+
+	n.mu.Lock()
+	n.connectNodes(0, 1)
+	n.connectNodes(1, 2)
+	n.mu.Unlock()
+
+	n.leave(eb, 1)
+	if state := <-states; state.NodeId != 1 || state.Online {
+		t.Errorf("Expected node 1 to go offline, got %v", state)
+	}
+	n.mu.Lock()
+	connections := n.getConnections()
+	n.mu.Unlock()
+	if len(connections) != 0 {
+		t.Errorf("Expected no connections, got %v", connections)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	n.join(eb, 1, "all", rng)
+	if state := <-states; state.NodeId != 1 || !state.Online {
+		t.Errorf("Expected node 1 to go online, got %v", state)
+	}
+	n.mu.Lock()
+	connections = n.getConnections()
+	n.mu.Unlock()
+	if len(connections) != 4 {
+		t.Errorf("Expected node 1 to connect to both nodes, got %v", connections)
+	}
+
+	// joining beyond the node count grows the network
+	n.join(eb, 4, "none", rng)
+	if cnt := n.nodeCount(); cnt != 5 {
+		t.Errorf("Expected 5 nodes, got %d", cnt)
+	}
+	if offline := n.offlineIds(); len(offline) != 1 || offline[0] != 3 {
+		t.Errorf("Expected node 3 to be offline, got %v", offline)
+	}
+}
+
+func TestNewChurnPlan_Invalid(t *testing.T) {
+	configs := []bus.Churn{
+		{Kind: "storm"},
+		{Kind: bus.PoissonChurn, ArrivalRate: 1},
+		{Kind: bus.PoissonChurn, ArrivalRate: 1, Session: bus.Session{Kind: bus.ParetoSession, Mean: time.Second, Shape: 1}},
+		{Kind: bus.PoissonChurn, ArrivalRate: 1, Session: bus.Session{Mean: time.Second}, Bootstrap: "ring"},
+		{Kind: bus.TraceChurn, Trace: "does-not-exist"},
+	}
+	for _, config := range configs {
+		if _, err := newChurnPlan(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+
+	if plan, err := newChurnPlan(bus.Churn{}); plan != nil || err != nil {
+		t.Errorf("Expected no plan without churn, got %v %v", plan, err)
+	}
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// isolates the data of a message from the sender according to the mode, so
+// nodes can not communicate through shared memory
+func isolate(mode bus.MessageMode, data any) (any, error) {
+	switch mode.Kind {
+	case bus.ShareMessages:
+		return data, nil
+	case bus.EncodeMessages:
+		return encodeCopy(data)
+	default:
+		return deepCopy(data)
+	}
+}
+
+// round trips the data through json, the receiver gets the data the way it
+// would arrive over a real network
+func encodeCopy(data any) (any, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var res any
+	err = json.Unmarshal(b, &res)
+	return res, err
+}
+
+// copies the data including everything it references, types are preserved.
+// Fails for values that can not be transmitted such as channels and funcs.
+func deepCopy(data any) (any, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	src := reflect.ValueOf(data)
+	dst := reflect.New(src.Type()).Elem()
+	c := copier{seen: make(map[seenKey]reflect.Value)}
+	if err := c.copy(dst, src); err != nil {
+		return nil, err
+	}
+	return dst.Interface(), nil
+}
+
+// identifies an already copied pointer or map, to preserve cycles and sharing
+// within a message
+type seenKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+type copier struct {
+	seen map[seenKey]reflect.Value
+}
+
+// dst has to be settable
+func (c *copier) copy(dst, src reflect.Value) error {
+	switch src.Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		if src.IsNil() {
+			return nil
+		}
+		return fmt.Errorf("a value of type %s can not be sent", src.Type())
+
+	case reflect.Pointer:
+		if src.IsNil() {
+			return nil
+		}
+		key := seenKey{src.Pointer(), src.Type()}
+		if p, ok := c.seen[key]; ok {
+			dst.Set(p)
+			return nil
+		}
+		p := reflect.New(src.Type().Elem())
+		c.seen[key] = p
+		if err := c.copy(p.Elem(), src.Elem()); err != nil {
+			return err
+		}
+		dst.Set(p)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return nil
+		}
+		elem := src.Elem()
+		v := reflect.New(elem.Type()).Elem()
+		if err := c.copy(v, elem); err != nil {
+			return err
+		}
+		dst.Set(v)
+
+	case reflect.Map:
+		if src.IsNil() {
+			return nil
+		}
+		key := seenKey{src.Pointer(), src.Type()}
+		if m, ok := c.seen[key]; ok {
+			dst.Set(m)
+			return nil
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.seen[key] = m
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			if err := c.copy(k, iter.Key()); err != nil {
+				return err
+			}
+			v := reflect.New(src.Type().Elem()).Elem()
+			if err := c.copy(v, iter.Value()); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return nil
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		for i := 0; i < src.Len(); i++ {
+			if err := c.copy(s.Index(i), src.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			if err := c.copy(dst.Index(i), src.Index(i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		// unexported fields are only accessible through addressable values
+		if !src.CanAddr() {
+			tmp := reflect.New(src.Type()).Elem()
+			tmp.Set(src)
+			src = tmp
+		}
+		if isInterpreted(src.Type()) {
+			return c.copyInterpreted(dst, src)
+		}
+		for i := 0; i < src.NumField(); i++ {
+			if err := c.copy(accessible(dst.Field(i)), accessible(src.Field(i))); err != nil {
+				return err
+			}
+		}
+
+	default:
+		dst.Set(src)
+	}
+
+	return nil
+}
+
+// values of interpreted types with methods are wrapped by the interpreter,
+// together with the description of their type
+func isInterpreted(t reflect.Type) bool {
+	return t.PkgPath() == "github.com/traefik/yaegi/interp" && t.Name() == "valueInterface"
+}
+
+// the type description belongs to the interpreter and is shared, only the
+// wrapped value is copied. src has to be addressable.
+func (c *copier) copyInterpreted(dst, src reflect.Value) error {
+	accessible(dst.FieldByName("node")).Set(accessible(src.FieldByName("node")))
+
+	wrapped := accessible(src.FieldByName("value")).Interface().(reflect.Value)
+	if !wrapped.IsValid() {
+		return nil
+	}
+	v := reflect.New(wrapped.Type()).Elem()
+	if err := c.copy(v, wrapped); err != nil {
+		return err
+	}
+	accessible(dst.FieldByName("value")).Set(reflect.ValueOf(v))
+	return nil
+}
+
+// makes an addressable field usable regardless of whether it is exported
+func accessible(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"distributed-sys-emulator/bus"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type copyTestData struct {
+	Values []int
+	Peers  map[string]*copyTestData
+	secret *int
+}
+
+func TestDeepCopy_No_Shared_Memory(t *testing.T) {
+	secret := 1
+	data := &copyTestData{Values: []int{1, 2}, Peers: map[string]*copyTestData{}, secret: &secret}
+	data.Peers["self"] = data
+
+	res, err := deepCopy(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copied, ok := res.(*copyTestData)
+	if !ok {
+		t.Fatalf("Expected the type to be preserved, got %T", res)
+	}
+
+	data.Values[0] = 42
+	secret = 42
+	if copied.Values[0] != 1 || *copied.secret != 1 {
+		t.Error("Copy shares memory with the original")
+	}
+	if copied.Peers["self"] != copied {
+		t.Error("Cycles should be preserved within the copy")
+	}
+}
+
+func TestDeepCopy_Unsendable(t *testing.T) {
+	unsendable := []any{
+		make(chan int),
+		func() {},
+		map[string]any{"f": func() {}},
+	}
+
+	for _, data := range unsendable {
+		if _, err := deepCopy(data); err == nil {
+			t.Errorf("Expected an error for %T", data)
+		}
+		if _, err := isolate(bus.MessageMode{Kind: bus.EncodeMessages}, data); err == nil {
+			t.Errorf("Expected an error when encoding %T", data)
+		}
+	}
+}
+
+// sends values of interpreted types, one of them with methods, and changes
+// them once they were sent
+const interpretedCopyCode = `package main
+
+import "context"
+
+type Msg struct {
+	V     int
+	Peers []int
+}
+
+func (m Msg) Sum() int {
+	return m.V + len(m.Peers)
+}
+
+type Plain struct {
+	V int
+}
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	m := Msg{1, []int{2}}
+	p := &Plain{3}
+	sent := fSend(1, m) + fSend(1, []any{p, &m})
+	m.Peers[0] = 42
+	p.V = 42
+	return sent
+}
+`
+
+func TestDeepCopy_Interpreted_Values(t *testing.T) {
+	prog := compileProgram(Code(interpretedCopyCode))
+	if prog.err != nil {
+		t.Fatal(prog.err)
+	}
+
+	var copies []any
+	fSend := func(to int, data any) int {
+		c, err := isolate(bus.MessageMode{Kind: bus.CopyMessages}, data)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		copies = append(copies, c)
+		return 1
+	}
+	res, _, err := interpretExec(context.Background(), prog, fSend, nil)
+	if err != nil || res != 2 {
+		t.Fatalf("Expected both messages to be sent, got %v, %v", res, err)
+	}
+
+	// the interpreter wraps values of types with methods
+	wrapped := reflect.New(reflect.TypeOf(copies[0])).Elem()
+	wrapped.Set(reflect.ValueOf(copies[0]))
+	if !isInterpreted(wrapped.Type()) {
+		t.Fatalf("Expected a value wrapped by the interpreter, got %T", copies[0])
+	}
+	msg := accessible(wrapped.FieldByName("value")).Interface().(reflect.Value)
+	if s := fmt.Sprint(msg); s != "{1 [2]}" {
+		t.Errorf("Expected the message as it was sent, got %s", s)
+	}
+
+	if s := fmt.Sprint(copies[1].([]any)[0], copies[1].([]any)[1]); s != "&{3} &{1 [2]}" {
+		t.Errorf("Expected the pointers as they were sent, got %s", s)
+	}
+}
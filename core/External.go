@@ -0,0 +1,57 @@
+package core
+
+import (
+	"distributed-sys-emulator/nodeproto"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+/* The external backend runs any program as a node, no matter the language it
+* is written in. Every node starts the configured command in a child process of
+* its own, which speaks nodeproto over its stdin and stdout : it reads an init
+* frame, sends and awaits messages and finally writes its result. The code in
+* the editor is not run, except for that of byzantine nodes.
+ */
+
+// runs the configured program for the node
+func (n *node) externalExec(ctx context.Context, command string, fSend sendFunc, fAwait awaitFunc) (any, string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, "", errors.New("the external backend requires a command")
+	}
+	return n.pipeExec(ctx, exec.Command(args[0], args[1:]...), "", fSend, fAwait)
+}
+
+// starts cmd and serves its requests, frames are exchanged over its stdin and
+// stdout while its stderr is passed on
+func (n *node) pipeExec(ctx context.Context, cmd *exec.Cmd, code string, fSend sendFunc, fAwait awaitFunc) (any, string, error) {
+	// frames are read from a pipe of our own, so they are not lost to Wait
+	// closing the pipe once the process exited
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, "", err
+	}
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		r.Close()
+		w.Close()
+		return nil, "", err
+	}
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return nil, "", err
+	}
+	w.Close()
+
+	c := nodeproto.NewConn(r, stdin)
+	supervise(ctx, c, cmd, waitExit(cmd), r, stdin)
+
+	return n.proxyExec(ctx, c, code, fSend, fAwait)
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/nodeproto"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// runs as the external program of a node when started by the tests below, it
+// returns the sum of its id and the data it received
+func TestExternalHelper(t *testing.T) {
+	if os.Getenv("P2PSIM_EXTERNAL_HELPER") == "" {
+		t.Skip("only run as an external program")
+	}
+
+	c := nodeproto.NewConn(os.Stdin, os.Stdout)
+	init, err := c.Read()
+	if err != nil {
+		os.Exit(1)
+	}
+	for _, peer := range init.OutNeighbors {
+		c.Write(nodeproto.Frame{Type: nodeproto.Send, Seq: 1, To: peer, Data: init.Id})
+	}
+	c.Write(nodeproto.Frame{Type: nodeproto.Await, Seq: 2, Cnt: len(init.InNeighbors)})
+
+	sum := float64(init.Id)
+	for {
+		f, err := c.Read()
+		if err != nil || f.Type == nodeproto.Stop {
+			os.Exit(0)
+		}
+		if f.Type == nodeproto.Messages {
+			for _, m := range f.Messages {
+				sum += m.Data.(float64)
+			}
+			c.Write(nodeproto.Frame{Type: nodeproto.Log, Text: "summed"})
+			c.Write(nodeproto.Frame{Type: nodeproto.Result, Data: sum})
+		}
+	}
+}
+
+// runs the command on a fully connected network of three nodes
+func runExternal(t *testing.T, command string) []bus.NodeOutput {
+	eb := bus.NewEventbus()
+	n := newNetwork(3, newNetEnv())
+	n.env.setBackend(bus.Backend{Kind: bus.ExternalBackend, Command: command})
+	n.setAndRunNodes(eb)
+	t.Cleanup(func() { n.emit(TERM) })
+
+	n.mu.Lock()
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if i != j {
+				n.connectNodes(i, j)
+			}
+		}
+	}
+	n.mu.Unlock()
+
+	results := make(chan bus.NodeOutput, 3)
+	eb.AwaitBind(bus.NodeOutputEvt, func(out bus.NodeOutput) {
+		results <- out
+	})
+	finished := make(chan bus.NodeId, 3)
+	eb.AwaitBind(bus.NodeFinishedEvt, func(id bus.NodeId) {
+		finished <- id
+	})
+
+	n.emit(START)
+	for i := 0; i < 3; i++ {
+		awaitFinished(t, finished)
+	}
+	n.emit(STOP)
+
+	outputs := make([]bus.NodeOutput, 3)
+	for i := 0; i < 3; i++ {
+		out := <-results
+		outputs[out.NodeId] = out
+	}
+	return outputs
+}
+
+func TestNetwork_External_Backend(t *testing.T) {
+	t.Setenv("P2PSIM_EXTERNAL_HELPER", "1")
+	outputs := runExternal(t, os.Args[0]+" -test.run=^TestExternalHelper$")
+
+	// every node receives the ids of the others, 0+1+2
+	for id, out := range outputs {
+		if out.Result != 3.0 || out.Log != "summed" {
+			t.Errorf("Expected node %d to return 3, got %v %q", id, out.Result, out.Log)
+		}
+	}
+}
+
+func TestNetwork_External_Python_Example(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+	example, err := filepath.Abs(filepath.Join("..", "resources", "external", "node.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, out := range runExternal(t, python+" "+example) {
+		if out.Result != 2.0 {
+			t.Errorf("Expected node %d to return the largest id, got %v %q", id, out.Result, out.Log)
+		}
+	}
+}
+
+func TestNetwork_External_Without_Command(t *testing.T) {
+	for id, out := range runExternal(t, "") {
+		if out.Log == "" {
+			t.Errorf("Expected node %d to report the missing command", id)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+/* Geography gives nodes coordinates within a square area. A message takes
+* Latency plus PerUnit for every unit of distance between its sender and
+* receiver to arrive, calls and their responses alike. With a Range the
+* connections form a unit disk graph : online nodes within range of each other
+* are connected in both directions, all others are not, even if connected by
+* hand. Nodes are placed again whenever they are added or removed, and with
+* custom placement whenever their custom data changes.
+ */
+
+const defaultGeoSize = 100
+
+type geoModel struct {
+	mu        sync.Mutex
+	config    bus.Geo
+	rng       *rand.Rand
+	positions []bus.Position // by node id, nil for circle placement
+	manual    map[int]bus.Position
+}
+
+func newGeoModel() *geoModel {
+	g := &geoModel{manual: make(map[int]bus.Position)}
+	g.configure(bus.Geo{Placement: bus.CirclePlacement})
+	return g
+}
+
+func validateGeo(config bus.Geo) error {
+	switch config.Placement {
+	case "", bus.CirclePlacement, bus.RandomPlacement, bus.CustomPlacement, bus.ManualPlacement:
+	default:
+		return fmt.Errorf("unknown placement %q", config.Placement)
+	}
+	if config.Size < 0 || config.Range < 0 {
+		return fmt.Errorf("the size and range of the area can not be negative")
+	}
+	if config.Latency < 0 || config.PerUnit < 0 {
+		return fmt.Errorf("latencies can not be negative")
+	}
+	return nil
+}
+
+// replaces the config, requires it to be valid. Previous positions are
+// dropped, except for the manually set ones.
+func (g *geoModel) configure(config bus.Geo) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if config.Placement == "" {
+		config.Placement = bus.CirclePlacement
+	}
+	if config.Size == 0 {
+		config.Size = defaultGeoSize
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	g.config = config
+	g.rng = rand.New(rand.NewSource(seed))
+	g.positions = nil
+}
+
+func (g *geoModel) getConfig() bus.Geo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.config
+}
+
+// stores the position of a node, used with manual placement
+func (g *geoModel) setManual(p bus.NodePosition) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.manual[p.NodeId] = p.Position
+}
+
+// computes the positions of the nodes, one per custom data. Randomly placed
+// nodes keep their position as long as they exist.
+func (g *geoModel) place(custom []any) bus.NodePositions {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cnt := len(custom)
+	size := g.config.Size
+	res := bus.NodePositions{Size: size, Range: g.config.Range}
+
+	switch g.config.Placement {
+	case bus.CirclePlacement:
+		g.positions = nil
+		return res
+	case bus.RandomPlacement:
+		if len(g.positions) > cnt {
+			g.positions = g.positions[:cnt]
+		}
+		for i := len(g.positions); i < cnt; i++ {
+			p := bus.Position{X: g.rng.Float64() * size, Y: g.rng.Float64() * size}
+			g.positions = append(g.positions, p)
+		}
+	case bus.CustomPlacement:
+		g.positions = make([]bus.Position, cnt)
+		for i, data := range custom {
+			p, ok := customPosition(data)
+			if !ok {
+				p = circlePosition(i, cnt, size)
+			}
+			g.positions[i] = p
+		}
+	case bus.ManualPlacement:
+		g.positions = make([]bus.Position, cnt)
+		for i := range g.positions {
+			p, ok := g.manual[i]
+			if !ok {
+				p = circlePosition(i, cnt, size)
+			}
+			g.positions[i] = p
+		}
+	}
+
+	res.Positions = append([]bus.Position(nil), g.positions...)
+	return res
+}
+
+// the "x" and "y" keys of json like custom data
+func customPosition(data any) (bus.Position, bool) {
+	m, ok := data.(map[string]any)
+	if !ok {
+		return bus.Position{}, false
+	}
+	x, okX := toFloat(m["x"])
+	y, okY := toFloat(m["y"])
+	return bus.Position{X: x, Y: y}, okX && okY
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// the position of node i of cnt evenly distributed on a circle in the area
+func circlePosition(i, cnt int, size float64) bus.Position {
+	angle := 2 * math.Pi * float64(i) / float64(cnt)
+	return bus.Position{
+		X: size/2 + .35*size*math.Cos(angle),
+		Y: size/2 + .35*size*math.Sin(angle),
+	}
+}
+
+func distance(a, b bus.Position) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+// the time a message takes from one node to another
+func (g *geoModel) latency(from, to int) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	l := g.config.Latency
+	if g.config.PerUnit > 0 && from < len(g.positions) && to < len(g.positions) {
+		d := distance(g.positions[from], g.positions[to])
+		l += time.Duration(d * float64(g.config.PerUnit))
+	}
+	return l
+}
+
+// the bidirectional connections between the given nodes within range of each
+// other, false if there is no range
+func (g *geoModel) inRange(ids []int) (bus.Connections, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.config.Range <= 0 || g.positions == nil {
+		return nil, false
+	}
+	var res bus.Connections
+	for _, a := range ids {
+		for _, b := range ids {
+			if a == b || a >= len(g.positions) || b >= len(g.positions) {
+				continue
+			}
+			if distance(g.positions[a], g.positions[b]) <= g.config.Range {
+				res = append(res, bus.Connection{From: a, To: b})
+			}
+		}
+	}
+	return res, true
+}
+
+// waits until a message transmitted at the given time has arrived, false if
+// the connection was closed or ctx is done first
+func (g *geoModel) travel(ctx context.Context, closed <-chan any, from, to int, transmitted time.Time) bool {
+	wait := time.Until(transmitted.Add(g.latency(from, to)))
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-closed:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
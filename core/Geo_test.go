@@ -0,0 +1,114 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"testing"
+	"time"
+)
+
+func TestGeoModel_Place(t *testing.T) {
+	g := newGeoModel()
+	if positions := g.place(make([]any, 3)); positions.Positions != nil {
+		t.Errorf("Expected no positions for circle placement, got %v", positions)
+	}
+
+	// random positions stay while the nodes exist
+	g.configure(bus.Geo{Placement: bus.RandomPlacement, Size: 10, Seed: 1})
+	first := g.place(make([]any, 3)).Positions
+	second := g.place(make([]any, 4)).Positions
+	for i, p := range first {
+		if p != second[i] {
+			t.Errorf("Expected node %d to stay at %v, got %v", i, p, second[i])
+		}
+	}
+	for _, p := range second {
+		if p.X < 0 || p.X > 10 || p.Y < 0 || p.Y > 10 {
+			t.Errorf("Expected %v within the area", p)
+		}
+	}
+
+	// custom data without coordinates falls back to the circle
+	g.configure(bus.Geo{Placement: bus.CustomPlacement})
+	custom := []any{map[string]any{"x": 1.5, "y": 2}, "foo"}
+	positions := g.place(custom).Positions
+	if positions[0] != (bus.Position{X: 1.5, Y: 2}) {
+		t.Errorf("Expected the position of the custom data, got %v", positions[0])
+	}
+	if positions[1] != circlePosition(1, 2, defaultGeoSize) {
+		t.Errorf("Expected a position on the circle, got %v", positions[1])
+	}
+}
+
+func TestGeoModel_Latency_Range(t *testing.T) {
+	g := newGeoModel()
+	g.configure(bus.Geo{Placement: bus.ManualPlacement, Latency: time.Millisecond, PerUnit: time.Millisecond, Range: 5})
+	g.setManual(bus.NodePosition{NodeId: 0, Position: bus.Position{X: 0, Y: 0}})
+	g.setManual(bus.NodePosition{NodeId: 1, Position: bus.Position{X: 3, Y: 4}})
+	g.setManual(bus.NodePosition{NodeId: 2, Position: bus.Position{X: 20, Y: 0}})
+	g.place(make([]any, 3))
+
+	if l := g.latency(0, 1); l != 6*time.Millisecond {
+		t.Errorf("Expected 6ms, got %v", l)
+	}
+	connections, ok := g.inRange([]int{0, 1, 2})
+	if !ok || len(connections) != 2 {
+		t.Errorf("Expected nodes 0 and 1 to be connected, got %v", connections)
+	}
+}
+
+func TestValidateGeo(t *testing.T) {
+	invalid := []bus.Geo{
+		{Placement: "grid"},
+		{Size: -1},
+		{Latency: -time.Second},
+	}
+	for _, config := range invalid {
+		if err := validateGeo(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestNetwork_Geo_Latency(t *testing.T) {
+	n, eb, finished := startTopologyTest(t, 2)
+	latency := 200 * time.Millisecond
+	n.setGeo(eb, bus.Geo{Latency: latency})
+
+	start := time.Now()
+	n.mu.Lock()
+	n.connectNodes(0, 1)
+	n.mu.Unlock()
+
+	awaitFinished(t, finished)
+	awaitFinished(t, finished)
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("Expected the message to take at least %v, took %v", latency, elapsed)
+	}
+}
+
+func TestNetwork_Geo_Range(t *testing.T) {
+	eb := bus.NewEventbus()
+	n := newNetwork(3, newNetEnv())
+	n.setAndRunNodes(eb)
+	t.Cleanup(func() { n.emit(TERM) })
+
+	n.setData(map[string]any{"x": 0.0, "y": 0.0}, 0)
+	n.setData(map[string]any{"x": 1.0, "y": 0.0}, 1)
+	n.setData(map[string]any{"x": 9.0, "y": 0.0}, 2)
+	n.mu.Lock()
+	n.connectNodes(1, 2)
+	n.mu.Unlock()
+
+	n.setGeo(eb, bus.Geo{Placement: bus.CustomPlacement, Range: 2})
+	n.mu.Lock()
+	connections := n.getConnections()
+	n.mu.Unlock()
+	if len(connections) != 2 {
+		t.Errorf("Expected nodes 0 and 1 to be connected only, got %v", connections)
+	}
+	for _, c := range connections {
+		if c.From == 2 || c.To == 2 {
+			t.Errorf("Expected node 2 to be out of range, got %v", c)
+		}
+	}
+}
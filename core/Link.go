@@ -0,0 +1,173 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// state of a network that is shared by all of its nodes
+type netEnv struct {
+	metrics       *metrics
+	links         *linkModel
+	reliabilities *reliabilities
+	geo           *geoModel
+	programs      *programs
+
+	workers *workerPool
+	natives *natives
+
+	mu      sync.Mutex
+	code    Code
+	backend bus.Backend
+	mode    bus.MessageMode
+	schema  any // custom data has to match it, nil for none
+	run     int // incremented for every run
+	calls   int // last id of a call
+
+	callTimeout time.Duration // how long fCall waits for a response
+	ticks       tickSource    // drives the retransmissions of reliable senders
+}
+
+func newNetEnv() *netEnv {
+	return &netEnv{
+		metrics:       newMetrics(),
+		links:         newLinkModel(),
+		reliabilities: newReliabilities(),
+		geo:           newGeoModel(),
+		programs:      newPrograms(),
+		workers:       newWorkerPool(),
+		natives:       newNatives(),
+		backend:       bus.Backend{Kind: bus.GoroutineBackend},
+		mode:          bus.MessageMode{Kind: bus.CopyMessages},
+		callTimeout:   defaultCallTimeout,
+		ticks:         realTicks,
+	}
+}
+
+func (env *netEnv) setCode(code Code) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.code = code
+}
+
+func (env *netEnv) getCode() Code {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.code
+}
+
+func (env *netEnv) setBackend(backend bus.Backend) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.backend = backend
+}
+
+func (env *netEnv) getBackend() bus.Backend {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.backend
+}
+
+func (env *netEnv) setMessageMode(mode bus.MessageMode) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.mode = mode
+}
+
+func (env *netEnv) getMessageMode() bus.MessageMode {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.mode
+}
+
+func (env *netEnv) setSchema(schema any) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.schema = schema
+}
+
+func (env *netEnv) getSchema() any {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.schema
+}
+
+func (env *netEnv) setCallTimeout(timeout time.Duration) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.callTimeout = timeout
+}
+
+func (env *netEnv) getCallTimeout() time.Duration {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.callTimeout
+}
+
+func (env *netEnv) setTicks(ticks tickSource) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.ticks = ticks
+}
+
+func (env *netEnv) getTicks() tickSource {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.ticks
+}
+
+func (env *netEnv) nextRun() {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.run++
+}
+
+func (env *netEnv) getRun() int {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	return env.run
+}
+
+func (env *netEnv) nextCall() int {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+	env.calls++
+	return env.calls
+}
+
+// models the unreliability of the links between nodes
+type linkModel struct {
+	mu   sync.Mutex
+	loss float64 // probability for a message to get lost
+	rng  *rand.Rand
+}
+
+func newLinkModel() *linkModel {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return &linkModel{rng: rng}
+}
+
+func (l *linkModel) configure(config bus.LinkConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.loss = config.Loss
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	l.rng = rand.New(rand.NewSource(seed))
+}
+
+// whether the next message should get lost
+func (l *linkModel) drop() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.loss <= 0 {
+		return false
+	}
+	return l.rng.Float64() < l.loss
+}
@@ -0,0 +1,225 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// interval in which metrics are published while nodes are running
+const metricsInterval = 500 * time.Millisecond
+
+// message as it is transmitted between two nodes
+type message struct {
+	data        any
+	sent        time.Time
+	transmitted time.Time // differs from sent for retransmissions
+	size        int       // bytes of the json serialized data
+	run         int       // reliable connections only
+	seq         int       // reliable connections only, starting at 1
+	call        *rpcCall  // requests of fCall only
+}
+
+func newMessage(data any) message {
+	size := 0
+	if b, err := json.Marshal(data); err == nil {
+		size = len(b)
+	}
+	now := time.Now()
+	return message{data: data, sent: now, transmitted: now, size: size}
+}
+
+type edgeStats struct {
+	bus.EdgeMetrics
+	delivered  int
+	discarded  int
+	latencySum time.Duration
+}
+
+// collects the cost of a run, shared by all nodes of a network
+type metrics struct {
+	mu    sync.Mutex
+	start time.Time
+	nodes map[int]*bus.NodeMetrics
+	edges map[bus.Connection]*edgeStats
+	done  chan any // closed to stop publishing
+}
+
+func newMetrics() *metrics {
+	m := &metrics{}
+	m.reset()
+	return m
+}
+
+func (m *metrics) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.start = time.Now()
+	m.nodes = make(map[int]*bus.NodeMetrics)
+	m.edges = make(map[bus.Connection]*edgeStats)
+}
+
+// requires the lock to be held
+func (m *metrics) node(id int) *bus.NodeMetrics {
+	nm, ok := m.nodes[id]
+	if !ok {
+		nm = &bus.NodeMetrics{NodeId: id}
+		m.nodes[id] = nm
+	}
+	return nm
+}
+
+// requires the lock to be held
+func (m *metrics) edge(from, to int) *edgeStats {
+	c := bus.Connection{From: from, To: to}
+	es, ok := m.edges[c]
+	if !ok {
+		es = &edgeStats{EdgeMetrics: bus.EdgeMetrics{Connection: c}}
+		m.edges[c] = es
+	}
+	return es
+}
+
+func (m *metrics) sent(from, to int, msg message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nm := m.node(from)
+	nm.Sent++
+	nm.BytesSent += msg.size
+
+	es := m.edge(from, to)
+	es.Messages++
+	es.Bytes += msg.size
+}
+
+// counts a message which is sent again, in addition to sent
+func (m *metrics) retransmitted(from, to int, msg message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nm := m.node(from)
+	nm.Sent++
+	nm.BytesSent += msg.size
+
+	es := m.edge(from, to)
+	es.Messages++
+	es.Bytes += msg.size
+	es.Retransmits++
+}
+
+// a message which arrived but is not delivered e.g. a duplicate
+func (m *metrics) discarded(from, to int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.edge(from, to).discarded++
+}
+
+func (m *metrics) lost(from, to int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.edge(from, to).Lost++
+}
+
+func (m *metrics) received(from, to int, msg message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nm := m.node(to)
+	nm.Received++
+	nm.BytesReceived += msg.size
+
+	es := m.edge(from, to)
+	es.delivered++
+	es.latencySum += time.Since(msg.sent)
+}
+
+func (m *metrics) awaited(id int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nm := m.node(id)
+	nm.AwaitTime += d
+	nm.Rounds++
+}
+
+func (m *metrics) snapshot(final bool) bus.Metrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := bus.Metrics{Elapsed: time.Since(m.start), Final: final}
+	for _, nm := range m.nodes {
+		res.Nodes = append(res.Nodes, *nm)
+		res.Messages += nm.Sent
+		res.Bytes += nm.BytesSent
+		if nm.Rounds > res.Rounds {
+			res.Rounds = nm.Rounds
+		}
+	}
+
+	for _, es := range m.edges {
+		em := es.EdgeMetrics
+		em.InFlight = es.Messages - es.delivered - es.discarded - es.Lost
+		if es.delivered > 0 {
+			em.Latency = es.latencySum / time.Duration(es.delivered)
+		}
+		res.Edges = append(res.Edges, em)
+	}
+
+	sort.Slice(res.Nodes, func(i, j int) bool {
+		return res.Nodes[i].NodeId < res.Nodes[j].NodeId
+	})
+	sort.Slice(res.Edges, func(i, j int) bool {
+		if res.Edges[i].From == res.Edges[j].From {
+			return res.Edges[i].To < res.Edges[j].To
+		}
+		return res.Edges[i].From < res.Edges[j].From
+	})
+
+	return res
+}
+
+// resets the metrics and starts publishing them
+func (m *metrics) startRun(eb bus.EventBus) {
+	m.stopRun()
+	m.reset()
+
+	m.mu.Lock()
+	m.done = make(chan any)
+	go m.publish(eb, m.done)
+	m.mu.Unlock()
+}
+
+func (m *metrics) stopRun() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.done != nil {
+		close(m.done)
+		m.done = nil
+	}
+}
+
+// publishes the metrics periodically until done is closed, followed by a final
+// summary
+func (m *metrics) publish(eb bus.EventBus, done <-chan any) {
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e := bus.Event{Type: bus.MetricsEvt, Data: m.snapshot(false)}
+			eb.Publish(e)
+		case <-done:
+			e := bus.Event{Type: bus.MetricsEvt, Data: m.snapshot(true)}
+			eb.Publish(e)
+			return
+		}
+	}
+}
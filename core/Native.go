@@ -0,0 +1,131 @@
+package core
+
+import (
+	"distributed-sys-emulator/codegen"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+/* The native backend compiles the code with the go toolchain instead of
+* interpreting it, see codegen.GenerateNative. Every node runs the compiled
+* worker in a child process of its own, talking nodeproto over its stdin and
+* stdout. The code is built once per change, the go toolchain has to be
+* installed.
+ */
+
+type nativeBuild struct {
+	done chan any // closed once the build finished
+	dir  string
+	exe  string
+	err  error
+}
+
+// workers compiled from the nodes code and that of byzantine nodes, by code
+type natives struct {
+	mu     sync.Mutex
+	builds map[Code]*nativeBuild
+}
+
+func newNatives() *natives {
+	return &natives{builds: make(map[Code]*nativeBuild)}
+}
+
+// builds the code on first use, waits for a build in progress
+func (ns *natives) get(code Code) (string, error) {
+	ns.mu.Lock()
+	b, ok := ns.builds[code]
+	if !ok {
+		b = &nativeBuild{done: make(chan any)}
+		ns.builds[code] = b
+		go func() {
+			b.dir, b.exe, b.err = buildNative(code)
+			close(b.done)
+		}()
+	}
+	ns.mu.Unlock()
+
+	<-b.done
+	return b.exe, b.err
+}
+
+// builds changed code, the workers of other code are removed
+func (ns *natives) replace(code Code) (string, error) {
+	ns.mu.Lock()
+	for c, b := range ns.builds {
+		if c == code {
+			continue
+		}
+		delete(ns.builds, c)
+		go func(b *nativeBuild) {
+			<-b.done
+			if b.dir != "" {
+				os.RemoveAll(b.dir)
+			}
+		}(b)
+	}
+	ns.mu.Unlock()
+
+	return ns.get(code)
+}
+
+// removes the workers of all code, once they are not required anymore
+func (ns *natives) remove() {
+	ns.mu.Lock()
+	builds := ns.builds
+	ns.builds = make(map[Code]*nativeBuild)
+	ns.mu.Unlock()
+
+	for _, b := range builds {
+		<-b.done
+		if b.dir != "" {
+			os.RemoveAll(b.dir)
+		}
+	}
+}
+
+// generates and builds the worker in a temporary directory
+func buildNative(code Code) (dir string, exe string, err error) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		return "", "", errors.New("the native backend requires the go toolchain : " + err.Error())
+	}
+
+	dir, err = os.MkdirTemp("", "p2psim-native-")
+	if err != nil {
+		return "", "", err
+	}
+	if err := codegen.GenerateNative(dir, string(code)); err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+
+	exe = filepath.Join(dir, "node")
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	cmd := exec.Command(goTool, "build", "-o", exe, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		return "", "", errors.New(strings.TrimSpace(string(out)))
+	}
+	return dir, exe, nil
+}
+
+// runs the compiled code in a child process, messages are serialized to json
+// as with the process backend
+func (n *node) nativeExec(ctx context.Context, code Code, fSend sendFunc, fAwait awaitFunc) (any, string, error) {
+	exe, err := n.env.natives.get(code)
+	if err != nil {
+		return nil, "", err
+	}
+	return n.pipeExec(ctx, exec.Command(exe), "", fSend, fAwait)
+}
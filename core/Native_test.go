@@ -0,0 +1,103 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"os/exec"
+	"testing"
+)
+
+// generic functions can not be interpreted
+const nativeTestCode = `package main
+
+import (
+	"context"
+	"fmt"
+	"distributed-sys-emulator/sim"
+)
+
+type config struct {
+	Greeting string
+}
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	if sim.ID(ctx) != 0 {
+		msgs := sim.Await(ctx, 1)
+		if len(msgs) == 0 {
+			return nil
+		}
+		return msgs[0].Data
+	}
+
+	c, err := sim.Custom[config](ctx)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print("sending ", c.Greeting)
+	sim.Broadcast(ctx, c.Greeting)
+	return "sent"
+}
+`
+
+func TestNetwork_Native_Backend(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	eb := bus.NewEventbus()
+	n := newNetwork(2, newNetEnv())
+	n.env.setCode(Code(nativeTestCode))
+	n.env.setBackend(bus.Backend{Kind: bus.NativeBackend})
+	t.Cleanup(n.env.natives.remove)
+	n.setAndRunNodes(eb)
+	t.Cleanup(func() { n.emit(TERM) })
+
+	n.setData(map[string]any{"greeting": "hello"}, 0)
+	n.mu.Lock()
+	n.connectNodes(0, 1)
+	n.mu.Unlock()
+
+	results := make(chan bus.NodeOutput, 2)
+	eb.AwaitBind(bus.NodeOutputEvt, func(out bus.NodeOutput) {
+		results <- out
+	})
+	finished := make(chan bus.NodeId, 2)
+	eb.AwaitBind(bus.NodeFinishedEvt, func(id bus.NodeId) {
+		finished <- id
+	})
+
+	// the first node to run builds the worker
+	if _, err := n.env.natives.get(Code(nativeTestCode)); err != nil {
+		t.Fatal(err)
+	}
+	n.emit(START)
+	for i := 0; i < 2; i++ {
+		awaitFinished(t, finished)
+	}
+	n.emit(STOP)
+
+	for i := 0; i < 2; i++ {
+		out := <-results
+		switch out.NodeId {
+		case 0:
+			if out.Result != "sent" || out.Log != "sending hello" {
+				t.Errorf("Expected node 0 to send, got %v %q", out.Result, out.Log)
+			}
+		case 1:
+			if out.Result != "hello" {
+				t.Errorf("Expected node 1 to receive the greeting, got %v %q", out.Result, out.Log)
+			}
+		}
+	}
+}
+
+func TestNatives_Build_Error(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	ns := newNatives()
+	t.Cleanup(ns.remove)
+	if _, err := ns.get("package main\n\nfunc Run() {}\n"); err == nil {
+		t.Error("Expected the build to fail without the required Run function")
+	}
+}
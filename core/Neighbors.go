@@ -0,0 +1,92 @@
+package core
+
+import (
+	"distributed-sys-emulator/log"
+	"fmt"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// called with the current neighbors of a node once its connections changed
+type topologyFunc = func(out, in []int)
+type neighborsFunc = func() (out, in []int)
+type onTopologyChangeFunc = func(f topologyFunc)
+
+// the neighbors of a node as seen by its running code, they change when
+// connections are added or removed during a run.
+//
+// Listeners are called in a goroutine of their own, so they may send, await or
+// call. Changes that happen while the listeners run are coalesced into a single
+// notification with the neighbors at that time.
+type neighbors struct {
+	mu        sync.Mutex
+	out, in   []int
+	listeners []topologyFunc
+	changed   chan any // holds at most one pending notification
+}
+
+func newNeighbors(ctx context.Context, out, in []int) *neighbors {
+	nb := &neighbors{out: out, in: in, changed: make(chan any, 1)}
+	go nb.notify(ctx)
+	return nb
+}
+
+// function to be used from user code (via the "neighbors" ctx key) to get the
+// current out- and in-neighbors
+func (nb *neighbors) get() (out, in []int) {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+	return append([]int{}, nb.out...), append([]int{}, nb.in...)
+}
+
+// function to be used from user code (via the "on-topology-change" ctx key) to
+// register a listener for changes of the neighbors
+func (nb *neighbors) onChange(f topologyFunc) {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+	nb.listeners = append(nb.listeners, f)
+}
+
+func (nb *neighbors) set(out, in []int) {
+	nb.mu.Lock()
+	nb.out, nb.in = out, in
+	nb.mu.Unlock()
+
+	select {
+	case nb.changed <- nil:
+	default:
+	}
+}
+
+func (nb *neighbors) notify(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-nb.changed:
+		}
+
+		nb.mu.Lock()
+		listeners := append([]topologyFunc{}, nb.listeners...)
+		nb.mu.Unlock()
+
+		out, in := nb.get()
+		for _, f := range listeners {
+			if err := callListener(f, out, in); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+// a panic in a listener should only affect this notification
+func callListener(f topologyFunc, out, in []int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("topology listener panic : %v", r)
+		}
+	}()
+	f(out, in)
+	return nil
+}
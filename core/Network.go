@@ -2,10 +2,19 @@ package core
 
 import (
 	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/codegen"
 	"distributed-sys-emulator/log"
+	"distributed-sys-emulator/schema"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
 )
 
-type Code string
+type Code = bus.Code
 
 type Signal int
 
@@ -14,132 +23,568 @@ const (
 	STOP  Signal = 2
 	TERM  Signal = 3
 	DEBUG Signal = 4
+	LEAVE Signal = 5 // stops the code without reporting a result
 )
 
 const initialNodeCnt = 2
 
+// after which unchanged code is compiled
+const compileDelay = 500 * time.Millisecond
+
 type Network interface {
 	Init(eb bus.EventBus)
 }
 
 type network struct {
+	mu      *sync.Mutex // events are handled concurrently
 	nodes   []Node
-	signals chan Signal
+	signals []chan Signal // one channel per node, so every node gets every signal
 	nodeCnt int
+	env     *netEnv
+	running Signal // START or DEBUG while the nodes run, so added nodes join
+
+	offline   map[int]bool // nodes that left, see churnPlan
+	churn     *churnPlan   // nil for no churn
+	stopChurn context.CancelFunc
 }
 
 func NewNetwork(eb bus.EventBus) Network {
-	var nodes []Node
-	signals := make(chan Signal, 10) // TODO : should the buffersize depend on nodecnt ?
-	cnt := initialNodeCnt
-	return network{nodes, signals, cnt}
+	return *newNetwork(initialNodeCnt, newNetEnv())
+}
+
+func newNetwork(cnt int, env *netEnv) *network {
+	return &network{mu: &sync.Mutex{}, nodeCnt: cnt, env: env, offline: make(map[int]bool)}
 }
 
 func (n network) Init(eb bus.EventBus) {
 	n.setAndRunNodes(eb)
 
+	// verify node results once a run has finished
+	newChecker().Init(eb)
+
 	// bind node handlers to the various relevant events
-	eb.Bind(bus.StartNodesEvt, func() { n.emit(START) })
+	eb.Bind(bus.StartNodesEvt, func() {
+		n.env.metrics.startRun(eb)
+		n.start(eb, START)
+	})
 
-	eb.Bind(bus.StopNodesEvt, func() { n.emit(STOP) })
+	eb.Bind(bus.StopNodesEvt, func() {
+		n.stop()
+		n.env.metrics.stopRun()
+	})
 
-	eb.Bind(bus.DebugNodesEvt, func() { n.emit(DEBUG) })
+	eb.Bind(bus.DebugNodesEvt, func() {
+		n.env.metrics.startRun(eb)
+		n.start(eb, DEBUG)
+	})
+
+	eb.Bind(bus.ChurnConfigChangeEvt, func(config bus.Churn) {
+		n.setChurn(eb, config)
+	})
+
+	eb.Bind(bus.GeoConfigChangeEvt, func(config bus.Geo) {
+		n.setGeo(eb, config)
+	})
+
+	eb.Bind(bus.NodePositionChangeEvt, func(p bus.NodePosition) {
+		n.env.geo.setManual(p)
+		if n.env.geo.getConfig().Placement == bus.ManualPlacement {
+			n.mu.Lock()
+			n.placeNodes(eb)
+			n.mu.Unlock()
+		}
+	})
 
 	eb.Bind(bus.ConnectNodesEvt, func(connData bus.Connection) {
+		n.mu.Lock()
 		n.connectNodes(connData.From, connData.To)
+		connections := n.getConnections()
+		n.mu.Unlock()
 
 		// publish event back to gui
-		connections := n.getConnections()
 		newEvent := bus.Event{Type: bus.NetworkConnectionsEvt, Data: connections}
 		eb.Publish(newEvent)
 	})
 
 	eb.Bind(bus.DisconnectNodesEvt, func(connData bus.Connection) {
+		n.mu.Lock()
 		n.disconnectNodes(connData.From, connData.To)
+		connections := n.getConnections()
+		n.mu.Unlock()
 
 		// publish event back to gui
-		connections := n.getConnections()
 		newEvent := bus.Event{Type: bus.NetworkConnectionsEvt, Data: connections}
 		eb.Publish(newEvent)
 	})
 
 	eb.Bind(bus.NodeDataChangeEvt, func(newData bus.NodeData) {
-		n.setData(newData, newData.TargetId)
+		n.setData(newData.Data, newData.TargetId)
+		if n.env.geo.getConfig().Placement == bus.CustomPlacement {
+			n.mu.Lock()
+			n.placeNodes(eb)
+			n.mu.Unlock()
+		}
+	})
+
+	eb.Bind(bus.ByzantineChangeEvt, func(b bus.Byzantine) {
+		if err := n.setByzantine(b); err != nil {
+			log.Error(err)
+		}
+	})
+
+	eb.Bind(bus.CustomSchemaChangeEvt, func(s bus.CustomSchema) {
+		n.env.setSchema(s.Schema)
+	})
+
+	eb.Bind(bus.CustomTemplateEvt, func(t bus.CustomTemplate) {
+		res := bus.CustomTemplateResult{}
+		if err := n.applyTemplate(eb, t); err != nil {
+			log.Error(err)
+			res.Err = err.Error()
+		}
+		evt := bus.Event{Type: bus.CustomTemplateResultEvt, Data: res}
+		eb.Publish(evt)
 	})
 
 	eb.Bind(bus.NodeCntChangeEvt, func(newCnt int) {
 		n.resize(eb, newCnt)
 	})
 
+	eb.Bind(bus.LinkConfigChangeEvt, func(config bus.LinkConfig) {
+		n.env.links.configure(config)
+	})
+
+	eb.Bind(bus.ReliabilityChangeEvt, func(config bus.Reliability) {
+		n.env.reliabilities.configure(config)
+	})
+
+	// the code is set right away, so a run always takes the latest code, but it
+	// changes with every keystroke. Compiling it is queued and coalesced, so
+	// the other subscribers are not held up.
+	eb.Bind(bus.CodeChangeEvt, func(code Code) {
+		n.env.setCode(code)
+	})
+	eb.Bind(bus.CodeChangeEvt, func(code Code) {
+		n.compile(eb, code)
+	}, bus.Queue(1, bus.CoalesceLatest))
+
+	// the code compiles differently for the native backend
+	eb.Bind(bus.BackendChangeEvt, func(backend bus.Backend) {
+		n.env.setBackend(backend)
+	})
+	eb.Bind(bus.BackendChangeEvt, func(backend bus.Backend) {
+		n.compile(eb, n.env.getCode())
+	}, bus.Queue(1, bus.CoalesceLatest))
+
+	eb.Bind(bus.MessageModeChangeEvt, func(mode bus.MessageMode) {
+		n.env.setMessageMode(mode)
+	})
+
+	eb.Bind(bus.CodeExportEvt, func(target bus.ExportTarget) {
+		res := bus.ExportResult{Dir: target.Dir}
+		if err := n.export(target.Dir); err != nil {
+			log.Error(err)
+			res.Err = err.Error()
+		}
+		evt := bus.Event{Type: bus.CodeExportResultEvt, Data: res}
+		eb.Publish(evt)
+	})
+
 	// publish the initial node count to the ui
 	resizeData := bus.NetworkResize{Connections: nil, Cnt: n.nodeCnt}
 	evt := bus.Event{Type: bus.NetworkResizeEvt, Data: resizeData}
 	eb.Publish(evt)
 }
 
+// adds or removes nodes at the end, the other nodes keep running and their
+// connections to removed nodes are closed. Added nodes join a running network.
 func (n *network) resize(eb bus.EventBus, newCnt int) {
-	// update node count
-	n.setNodeCnt(newCnt)
-
-	// store connections
-	oldNetworkC := n.getConnections()
-
-	// restart nodes
-	n.emit(TERM)
-	n.setAndRunNodes(eb)
+	if newCnt < 0 {
+		return
+	}
 
-	// set connections
-	var newNetworkC bus.Connections
-	for _, nodeC := range oldNetworkC {
-		if nodeC.From < newCnt && nodeC.To < newCnt {
-			newNetworkC = append(newNetworkC, nodeC)
-			n.connectNodes(nodeC.From, nodeC.To)
+	n.mu.Lock()
+	oldCnt := len(n.nodes)
+	for _, c := range n.getConnections() {
+		if c.From >= newCnt || c.To >= newCnt {
+			n.disconnectNodes(c.From, c.To)
 		}
 	}
+	for i := newCnt; i < oldCnt; i++ {
+		n.signals[i] <- TERM
+		delete(n.offline, i)
+	}
+	if newCnt < oldCnt {
+		n.nodes = n.nodes[:newCnt]
+		n.signals = n.signals[:newCnt]
+	}
+	for i := oldCnt; i < newCnt; i++ {
+		n.runNode(eb, i)
+		if n.running != 0 {
+			n.signals[i] <- n.running
+		}
+	}
+	n.setNodeCnt(newCnt)
+	n.placeNodes(eb)
+	connections := n.getConnections()
+	n.mu.Unlock()
 
 	// send NetworkNodeCntChangeEvt
-	resizeData := bus.NetworkResize{Connections: newNetworkC, Cnt: newCnt}
+	resizeData := bus.NetworkResize{Connections: connections, Cnt: newCnt}
 	sizeEvt := bus.Event{Type: bus.NetworkResizeEvt, Data: resizeData}
 	eb.Publish(sizeEvt)
 }
 
+// generates the custom data of every node, nothing is changed if the data of
+// any node does not match the schema
+func (n *network) applyTemplate(eb bus.EventBus, t bus.CustomTemplate) error {
+	n.mu.Lock()
+	cnt := n.nodeCnt
+	n.mu.Unlock()
+
+	custom, err := GenerateCustom(t.Template, cnt, t.Seed)
+	if err != nil {
+		return err
+	}
+	if err := validateCustom(n.env.getSchema(), custom); err != nil {
+		return err
+	}
+
+	for i, data := range custom {
+		newData := bus.NodeData{TargetId: i, Data: data}
+		evt := bus.Event{Type: bus.NodeDataChangeEvt, Data: newData}
+		eb.Publish(evt)
+	}
+	return nil
+}
+
+func validateCustom(s any, custom []any) error {
+	if s == nil {
+		return nil
+	}
+	for i, data := range custom {
+		if err := schema.Validate(s, data); err != nil {
+			return fmt.Errorf("node %d : %w", i, err)
+		}
+	}
+	return nil
+}
+
+// generate a standalone module running the current code and topology
+func (n *network) export(dir string) error {
+	n.mu.Lock()
+	custom := make([]any, len(n.nodes))
+	for i, node := range n.nodes {
+		custom[i] = node.GetData()
+	}
+	connections := n.getConnections()
+	n.mu.Unlock()
+
+	spec := codegen.Spec{
+		Code:        string(n.env.getCode()),
+		Connections: connections,
+		Custom:      custom,
+	}
+	return codegen.Generate(dir, spec)
+}
+
 func (n *network) setNodeCnt(cnt int) {
 	n.nodeCnt = cnt
 }
 
 func (n *network) setData(json any, toId int) {
-	n.nodes[toId].SetData(json)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if toId >= 0 && toId < len(n.nodes) {
+		n.nodes[toId].SetData(json)
+	}
+}
+
+// the behaviour applies from the next run on
+func (n *network) setByzantine(b bus.Byzantine) error {
+	if err := validateByzantine(b); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.valid(b.NodeId) {
+		return fmt.Errorf("node %d does not exist", b.NodeId)
+	}
+	n.nodes[b.NodeId].SetByzantine(b)
+	return nil
 }
 
-func (n network) connectNodes(fromId, toId int) {
-	c := make(chan any, 10)
-	n.nodes[fromId].AddOutputTo(toId, c)
-	n.nodes[toId].AddInputFrom(fromId, c)
+// connects two nodes, even while they run. Requires the lock to be held.
+func (n *network) connectNodes(fromId, toId int) {
+	if !n.valid(fromId) || !n.valid(toId) || fromId == toId {
+		return
+	}
+	for _, c := range n.nodes[fromId].GetOutConnections() {
+		if c.To == toId {
+			return
+		}
+	}
+
+	l := newLink()
+	n.nodes[fromId].AddOutputTo(toId, l)
+	n.nodes[toId].AddInputFrom(fromId, l)
 }
 
-func (n network) disconnectNodes(fromId, toId int) {
+// requires the lock to be held
+func (n *network) disconnectNodes(fromId, toId int) {
+	if !n.valid(fromId) || !n.valid(toId) {
+		return
+	}
 	n.nodes[fromId].DelOutputTo(toId)
 	n.nodes[toId].DelInputFrom(fromId)
 }
 
+func (n *network) valid(id int) bool {
+	return id >= 0 && id < len(n.nodes)
+}
+
 func (n *network) setAndRunNodes(eb bus.EventBus) {
-	cnt := n.nodeCnt
-	n.nodes = make([]Node, cnt)
-	for i := 0; i < cnt; i++ {
-		newNode := NewNode(i)
-		go newNode.Run(eb, n.signals)
-		n.nodes[i] = newNode
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.nodes = nil
+	n.signals = nil
+	for i := 0; i < n.nodeCnt; i++ {
+		n.runNode(eb, i)
+	}
+}
+
+// adds a node with the next id, requires the lock to be held
+func (n *network) runNode(eb bus.EventBus, id int) {
+	newNode := NewNode(id, n.env)
+	signals := make(chan Signal, 10)
+	go newNode.Run(eb, signals)
+	n.nodes = append(n.nodes, newNode)
+	n.signals = append(n.signals, signals)
+}
+
+// starts a run, nodes that left during the previous run join again
+func (n *network) start(eb bus.EventBus, s Signal) {
+	if kind := n.env.getBackend().Kind; kind != bus.NativeBackend && kind != bus.ExternalBackend {
+		if err := n.env.programs.get(n.env.getCode()).err; err != nil {
+			log.Error(err, "the code does not compile")
+		}
+	}
+
+	n.mu.Lock()
+	plan := n.churn
+	n.mu.Unlock()
+
+	rng := rand.New(rand.NewSource(0))
+	bootstrap := ""
+	if plan != nil {
+		rng = plan.rng()
+		bootstrap = plan.config.Bootstrap
+	}
+	for _, id := range n.offlineIds() {
+		n.join(eb, id, bootstrap, rng)
+	}
+
+	n.emit(s)
+
+	if plan != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		n.mu.Lock()
+		n.stopChurn = cancel
+		n.mu.Unlock()
+		go plan.run(ctx, eb, n)
+	}
+}
+
+func (n *network) stop() {
+	n.mu.Lock()
+	if n.stopChurn != nil {
+		n.stopChurn()
+		n.stopChurn = nil
+	}
+	n.mu.Unlock()
+
+	n.emit(STOP)
+}
+
+// compiles changed code once for all nodes and publishes whether it compiles.
+// The code changes with every keystroke in the editor, so it is only compiled
+// once it did not change for a while, in the background. A run started before
+// compiles the code it runs on its own.
+func (n *network) compile(eb bus.EventBus, code Code) {
+	backend := n.env.getBackend().Kind
+	if backend == bus.ExternalBackend {
+		// the program does not run the code
+		eb.Publish(bus.Event{Type: bus.CodeCompileResultEvt, Data: bus.CodeCompileResult{}})
+		return
+	}
+
+	time.AfterFunc(compileDelay, func() {
+		if n.env.getCode() != code {
+			return
+		}
+		var err error
+		if backend == bus.NativeBackend {
+			_, err = n.env.natives.replace(code)
+		} else {
+			err = n.env.programs.replace(code).err
+		}
+		res := bus.CodeCompileResult{}
+		if err != nil {
+			res.Err = err.Error()
+		}
+		if n.env.getCode() == code {
+			eb.Publish(bus.Event{Type: bus.CodeCompileResultEvt, Data: res})
+		}
+	})
+}
+
+func (n *network) onlineIds() []int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.online()
+}
+
+// requires the lock to be held
+func (n *network) online() []int {
+	var res []int
+	for id := range n.nodes {
+		if !n.offline[id] {
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+func (n *network) offlineIds() []int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var res []int
+	for id := range n.offline {
+		res = append(res, id)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// a node joins the network, connected according to the bootstrap rule. Ids
+// beyond the node count add nodes, the ones in between start offline.
+func (n *network) join(eb bus.EventBus, id int, bootstrap string, rng *rand.Rand) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	grown := id >= len(n.nodes)
+	for i := len(n.nodes); i <= id; i++ {
+		n.runNode(eb, i)
+		n.offline[i] = true
+	}
+	n.setNodeCnt(len(n.nodes))
+	if !n.offline[id] {
+		return
 	}
+
+	// nodes within range connect to each other instead of bootstrapping
+	connections, err := Bootstrap(bootstrap, id, n.online(), rng)
+	if err != nil {
+		log.Error(err)
+	}
+	delete(n.offline, id)
+	inRange := n.env.geo.getConfig().Range > 0
+	if grown || inRange {
+		n.placeNodes(eb)
+	}
+	if !inRange {
+		for _, c := range connections {
+			n.connectNodes(c.From, c.To)
+		}
+	}
+	all := n.getConnections()
+
+	// published while the lock is held, so the events of a node are in order
+	if grown {
+		resizeData := bus.NetworkResize{Connections: all, Cnt: len(n.nodes)}
+		eb.AwaitPublish(bus.Event{Type: bus.NetworkResizeEvt, Data: resizeData})
+		for i := range n.nodes {
+			if n.offline[i] {
+				state := bus.NodeState{NodeId: i, Online: false}
+				eb.AwaitPublish(bus.Event{Type: bus.NodeStateEvt, Data: state})
+			}
+		}
+	}
+	state := bus.NodeState{NodeId: id, Online: true}
+	eb.AwaitPublish(bus.Event{Type: bus.NodeStateEvt, Data: state})
+	eb.Publish(bus.Event{Type: bus.NetworkConnectionsEvt, Data: all})
+
+	if n.running != 0 {
+		n.signals[id] <- n.running
+	}
+}
+
+// a random offline node joins the network, or a new one if every node is
+// online. Returns its id.
+func (n *network) arrive(eb bus.EventBus, bootstrap string, rng *rand.Rand) int {
+	offline := n.offlineIds()
+	id := n.nodeCount()
+	if len(offline) > 0 {
+		id = offline[rng.Intn(len(offline))]
+	}
+	n.join(eb, id, bootstrap, rng)
+	return id
+}
+
+// a node leaves the network, its code is stopped and its connections closed
+func (n *network) leave(eb bus.EventBus, id int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.valid(id) || n.offline[id] {
+		return
+	}
+	for _, c := range n.getConnections() {
+		if c.From == id || c.To == id {
+			n.disconnectNodes(c.From, c.To)
+		}
+	}
+	n.offline[id] = true
+	n.signals[id] <- LEAVE
+
+	state := bus.NodeState{NodeId: id, Online: false}
+	eb.AwaitPublish(bus.Event{Type: bus.NodeStateEvt, Data: state})
+	eb.Publish(bus.Event{Type: bus.NetworkConnectionsEvt, Data: n.getConnections()})
+}
+
+func (n *network) nodeCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.nodes)
 }
 
 func (n *network) emit(s Signal) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	log.Debug("Emit signal to nodes : ", s)
-	for range n.nodes {
-		n.signals <- s
+	switch s {
+	case START, DEBUG:
+		n.env.nextRun()
+		n.running = s
+	case STOP, TERM:
+		n.running = 0
+	}
+	for _, signals := range n.signals {
+		signals <- s
+	}
+	// the network is torn down, its worker processes are not required anymore
+	if s == TERM {
+		n.env.workers.close()
 	}
 }
 
-// returns exactly one connections slice for each node
+// returns exactly one connections slice for each node, requires the lock to be
+// held
 func (n *network) getConnections() bus.Connections {
 	var res bus.Connections
 	for _, node := range n.nodes {
@@ -149,3 +594,48 @@ func (n *network) getConnections() bus.Connections {
 
 	return res
 }
+
+// validates and applies the geo config, publishes whether it was applied
+func (n *network) setGeo(eb bus.EventBus, config bus.Geo) {
+	res := bus.GeoConfigResult{}
+	if err := validateGeo(config); err != nil {
+		log.Error(err)
+		res.Err = err.Error()
+	} else {
+		n.env.geo.configure(config)
+		n.mu.Lock()
+		n.placeNodes(eb)
+		n.mu.Unlock()
+	}
+	eb.Publish(bus.Event{Type: bus.GeoConfigResultEvt, Data: res})
+}
+
+// moves the nodes according to the geo config, with a range the online nodes
+// are connected to the ones within range only. Requires the lock to be held.
+func (n *network) placeNodes(eb bus.EventBus) {
+	custom := make([]any, len(n.nodes))
+	for i, node := range n.nodes {
+		custom[i] = node.GetData()
+	}
+	positions := n.env.geo.place(custom)
+	eb.Publish(bus.Event{Type: bus.NodePositionsEvt, Data: positions})
+
+	connections, ok := n.env.geo.inRange(n.online())
+	if !ok {
+		return
+	}
+	// connections that stay are kept open, so no messages get lost
+	wanted := make(map[bus.Connection]bool)
+	for _, c := range connections {
+		wanted[c] = true
+	}
+	for _, c := range n.getConnections() {
+		if !wanted[c] {
+			n.disconnectNodes(c.From, c.To)
+		}
+	}
+	for _, c := range connections {
+		n.connectNodes(c.From, c.To)
+	}
+	eb.Publish(bus.Event{Type: bus.NetworkConnectionsEvt, Data: n.getConnections()})
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"context"
+	"distributed-sys-emulator/bus"
+	"testing"
+	"time"
+)
+
+const topologyTestCode = `package main
+
+import (
+	"context"
+	"distributed-sys-emulator/sim"
+)
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	if sim.ID(ctx) != 0 {
+		msgs := sim.Await(ctx, 1)
+		if len(msgs) == 0 {
+			return nil
+		}
+		return msgs[0].Data
+	}
+
+	changed := make(chan []int, 1)
+	sim.OnTopologyChange(ctx, func(out, in []int) {
+		select {
+		case changed <- out:
+		default:
+		}
+	})
+
+	out := sim.OutNeighbors(ctx)
+	for len(out) == 0 {
+		select {
+		case out = <-changed:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	sim.Send(ctx, out[0], "hello")
+	return len(out)
+}
+`
+
+// runs the network with the code until stopped, finished receives the ids of
+// the nodes whose code returned
+func startTopologyTest(t *testing.T, cnt int) (*network, bus.EventBus, chan bus.NodeId) {
+	eb := bus.NewEventbus()
+
+	finished := make(chan bus.NodeId, 10)
+	eb.AwaitBind(bus.NodeFinishedEvt, func(id bus.NodeId) {
+		finished <- id
+	})
+
+	n := newNetwork(cnt, newNetEnv())
+	n.env.setCode(Code(topologyTestCode))
+	n.setAndRunNodes(eb)
+	n.emit(START)
+	t.Cleanup(func() { n.emit(TERM) })
+	return n, eb, finished
+}
+
+func awaitFinished(t *testing.T, finished <-chan bus.NodeId) bus.NodeId {
+	select {
+	case id := <-finished:
+		return id
+	case <-time.After(10 * time.Second):
+		t.Fatal("Node did not finish")
+	}
+	return -1
+}
+
+func TestNetwork_Connect_While_Running(t *testing.T) {
+	n, eb, finished := startTopologyTest(t, 2)
+
+	n.mu.Lock()
+	n.connectNodes(0, 1)
+	n.mu.Unlock()
+
+	awaitFinished(t, finished)
+	awaitFinished(t, finished)
+
+	results := make(chan bus.NodeOutput, 2)
+	eb.AwaitBind(bus.NodeOutputEvt, func(out bus.NodeOutput) {
+		results <- out
+	})
+	n.emit(STOP)
+
+	for i := 0; i < 2; i++ {
+		out := <-results
+		if out.NodeId == 0 && out.Result != 1 {
+			t.Errorf("Expected node 0 to see one out-neighbor, got %v %s", out.Result, out.Log)
+		}
+		if out.NodeId == 1 && out.Result != "hello" {
+			t.Errorf("Expected node 1 to receive the message, got %v %s", out.Result, out.Log)
+		}
+	}
+}
+
+func TestNetwork_Resize_While_Running(t *testing.T) {
+	n, eb, finished := startTopologyTest(t, 2)
+
+	// the new node joins the run, the others keep running
+	n.resize(eb, 3)
+	n.mu.Lock()
+	n.connectNodes(0, 2)
+	n.mu.Unlock()
+
+	if id := awaitFinished(t, finished); id != 0 && id != 2 {
+		t.Errorf("Expected node 0 or 2 to finish, got %d", id)
+	}
+	if id := awaitFinished(t, finished); id != 0 && id != 2 {
+		t.Errorf("Expected node 0 or 2 to finish, got %d", id)
+	}
+
+	// removing the new node closes its connections
+	n.resize(eb, 2)
+	n.mu.Lock()
+	connections := n.getConnections()
+	n.mu.Unlock()
+	if len(connections) != 0 {
+		t.Errorf("Expected no connections, got %v", connections)
+	}
+}
+
+func TestNetwork_Connect_Request(t *testing.T) {
+	eb := bus.NewEventbus()
+	n := newNetwork(2, newNetEnv())
+	n.Init(eb)
+	t.Cleanup(func() { n.emit(TERM) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := bus.Connection{From: 0, To: 1}
+	connections, err := bus.NetworkConnectionsTopic.AwaitReply(ctx, eb, bus.ConnectNodesTopic.Event(c), func(cs bus.Connections) bool {
+		return len(cs) > 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(connections) != 1 || connections[0] != c {
+		t.Errorf("Expected the new connection, got %v", connections)
+	}
+}
+
+const debugTestCode = `package main
+
+import (
+	"context"
+	"distributed-sys-emulator/sim"
+)
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	if sim.ID(ctx) == 0 {
+		return fSend(1, "step")
+	}
+	return fAwait(1)[0]
+}
+`
+
+func TestNetwork_Debug_Steps(t *testing.T) {
+	eb := bus.NewEventbus()
+
+	// every step is continued right away, which the nodes must not miss
+	steps := make(chan bus.EventType, 2)
+	eb.AwaitBindWildcard("*", func(e bus.Event) {
+		steps <- e.Type
+		bus.ContinueNodesTopic.Publish(eb)
+	}, bus.Where(func(e bus.Event) bool {
+		return e.Type == bus.SentToEvt || e.Type == bus.AwaitEndEvt
+	}))
+	finished := make(chan bus.NodeId, 2)
+	eb.AwaitBind(bus.NodeFinishedEvt, func(id bus.NodeId) {
+		finished <- id
+	})
+
+	n := newNetwork(2, newNetEnv())
+	n.env.setCode(Code(debugTestCode))
+	n.setAndRunNodes(eb)
+	t.Cleanup(func() { n.emit(TERM) })
+	n.mu.Lock()
+	n.connectNodes(0, 1)
+	n.mu.Unlock()
+
+	n.emit(DEBUG)
+	awaitFinished(t, finished)
+	awaitFinished(t, finished)
+
+	seen := map[bus.EventType]bool{<-steps: true, <-steps: true}
+	if !seen[bus.SentToEvt] || !seen[bus.AwaitEndEvt] {
+		t.Errorf("Expected a send and an await step, got %v", seen)
+	}
+}
@@ -1,74 +1,140 @@
 package core
 
 import (
-	"bytes"
 	"distributed-sys-emulator/bus"
 	"distributed-sys-emulator/log"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/traefik/yaegi/interp"
-	"github.com/traefik/yaegi/stdlib"
 	"golang.org/x/net/context"
 )
 
 type Node interface {
-	AddOutputTo(peerId int, c chan any)
+	AddOutputTo(peerId int, l link)
 	DelOutputTo(peerId int)
-	AddInputFrom(peerId int, c chan any)
+	AddInputFrom(peerId int, l link)
 	DelInputFrom(peerId int)
 	GetOutConnections() bus.Connections
 	SetData(json any)
+	GetData() any
+	SetByzantine(b bus.Byzantine)
+	GetByzantine() bus.Byzantine
 	Run(eb bus.EventBus, signals <-chan Signal)
 }
 
+type sendFunc = func(targetId int, data any) int
+type awaitFunc = func(cnt int) []any
+type runFunc = func(ctx context.Context, fSend sendFunc, fAwait awaitFunc) any
+
+// the channels between two nodes, calls are kept apart from messages so a node
+// can serve calls while messages are waiting to be awaited
+type link struct {
+	ch     chan message
+	calls  chan message
+	closed chan any // closed once the nodes are disconnected
+}
+
+func newLink() link {
+	return link{make(chan message, 10), make(chan message, 10), make(chan any)}
+}
+
 // stores a connection between this node and another peer
 // whether its in- or outgoing depends on the context
 type connection struct {
 	peer int
-	ch   chan any
+	link
 }
 
 type node struct {
+	mu   sync.Mutex   // connections may change while the code runs
 	ins  []connection // stores connections TO other nodes
 	outs []connection // stores connections FROM other nodes
 	id   int
 	data any // json data to expose to user code
+	env  *netEnv
+	exec *execution // set while the code runs
+
+	byzantine bus.Byzantine // applies from the next run on
+}
+
+// the parts of a running codeExec that follow changes of the connections
+type execution struct {
+	ctx       context.Context
+	run       int
+	inbox     chan bus.SendTask
+	server    *rpcServer
+	senders   map[int]*reliableSender // reliable outgoing connections only
+	neighbors *neighbors
+	adversary *adversary // nil for honest nodes
 }
 
-func NewNode(id int) Node {
-	var ins []connection
-	var outs []connection
-	return &node{ins, outs, id, nil}
+func NewNode(id int, env *netEnv) Node {
+	return &node{id: id, env: env}
 }
 
-func (n *node) AddOutputTo(peerId int, c chan any) {
-	newConnection := connection{peerId, c}
+func (n *node) AddOutputTo(peerId int, l link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	newConnection := connection{peerId, l}
 	n.outs = append(n.outs, newConnection)
+	if n.exec != nil {
+		n.serveOut(n.exec, newConnection)
+		n.exec.neighbors.set(n.neighborIds())
+	}
 }
 
+// the link is closed, messages which are still in it are lost
 func (n *node) DelOutputTo(peerId int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	for connI, conn := range n.outs {
 		if conn.peer == peerId {
 			n.outs = append(n.outs[:connI], n.outs[connI+1:]...)
+			close(conn.closed)
+			if n.exec != nil {
+				delete(n.exec.senders, peerId)
+				n.exec.neighbors.set(n.neighborIds())
+			}
 			return
 		}
 	}
 }
 
-func (n *node) AddInputFrom(peerId int, c chan any) {
-	newConnection := connection{peerId, c}
+func (n *node) AddInputFrom(peerId int, l link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	newConnection := connection{peerId, l}
 	n.ins = append(n.ins, newConnection)
+	if n.exec != nil {
+		n.serveIn(n.exec, newConnection)
+		n.exec.neighbors.set(n.neighborIds())
+	}
 }
 
 func (n *node) DelInputFrom(peerId int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	for connI, conn := range n.ins {
 		if conn.peer == peerId {
 			n.ins = append(n.ins[:connI], n.ins[connI+1:]...)
+			if n.exec != nil {
+				n.exec.neighbors.set(n.neighborIds())
+			}
 			return
 		}
 	}
 }
 
 func (n *node) GetOutConnections() bus.Connections {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	res := make(bus.Connections, len(n.outs))
 	for i, c := range n.outs {
 		res[i] = bus.Connection{From: n.id, To: c.peer}
@@ -77,22 +143,76 @@ func (n *node) GetOutConnections() bus.Connections {
 }
 
 func (n *node) SetData(json any) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	n.data = json
 }
 
-// a node will run continuously, the current state can be changed using signals
-func (n *node) Run(eb bus.EventBus, signals <-chan Signal) {
-	code := Code("")
-	updateCode := func(newCode Code) {
-		code = newCode
-		log.Debug("node ", n.id, " received code")
+func (n *node) SetByzantine(b bus.Byzantine) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.byzantine = b
+}
+
+func (n *node) GetByzantine() bus.Byzantine {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.byzantine
+}
+
+func (n *node) GetData() any {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.data
+}
+
+// requires the lock to be held
+func (n *node) neighborIds() (out, in []int) {
+	out = make([]int, len(n.outs))
+	for i, c := range n.outs {
+		out[i] = c.peer
 	}
-	eb.Bind(bus.CodeChangeEvt, updateCode)
+	in = make([]int, len(n.ins))
+	for i, c := range n.ins {
+		in[i] = c.peer
+	}
+	return out, in
+}
 
+// requires the lock to be held
+func (n *node) outTo(peerId int) (connection, bool) {
+	for _, c := range n.outs {
+		if c.peer == peerId {
+			return c, true
+		}
+	}
+	return connection{}, false
+}
+
+// receives messages and calls from an incoming connection for the rest of the
+// run, requires the lock to be held
+func (n *node) serveIn(e *execution, c connection) {
+	kind := n.env.reliabilities.get(c.peer, n.id).Kind
+	go n.receive(e.ctx, c, newReliableReceiver(kind, e.run), e.inbox)
+	go n.serveCalls(e.ctx, c, e.server, e.run)
+}
+
+// requires the lock to be held
+func (n *node) serveOut(e *execution, c connection) {
+	config := n.env.reliabilities.get(n.id, c.peer)
+	if config.Kind != bus.Unreliable {
+		e.senders[c.peer] = newReliableSender(e.ctx, n.id, c, n.env, config.Timeout)
+	}
+}
+
+// a node will run continuously, the current state can be changed using signals
+func (n *node) Run(eb bus.EventBus, signals <-chan Signal) {
 	var codeCancel chan any
-	resChan := make(chan bus.NodeOutput)
+	// one per execution and buffered, so the code of a node that left or was
+	// terminated can still finish
+	var resChan chan bus.NodeOutput
 
-	// wait for other signals
+	// wait for signals, a run takes the code of the network at its start
 	running := false
 	for sig := range signals {
 		log.Debug("Node ", n.id, " received signal ", sig)
@@ -100,13 +220,15 @@ func (n *node) Run(eb bus.EventBus, signals <-chan Signal) {
 		case START:
 			if !running {
 				codeCancel = make(chan any, 1)
-				go n.codeExec(eb, codeCancel, code, resChan, false)
+				resChan = make(chan bus.NodeOutput, 1)
+				go n.codeExec(eb, codeCancel, n.env.getCode(), resChan, false)
 				running = true
 			}
 		case DEBUG:
 			if !running {
 				codeCancel = make(chan any, 1)
-				go n.codeExec(eb, codeCancel, code, resChan, true)
+				resChan = make(chan bus.NodeOutput, 1)
+				go n.codeExec(eb, codeCancel, n.env.getCode(), resChan, true)
 				running = true
 			}
 		case STOP:
@@ -118,12 +240,15 @@ func (n *node) Run(eb bus.EventBus, signals <-chan Signal) {
 				eb.Publish(e)
 				running = false
 			}
+		case LEAVE:
+			if running {
+				close(codeCancel)
+				running = false
+			}
 		case TERM:
 			if running {
 				close(codeCancel)
 			}
-			close(resChan)
-			eb.Unbind(bus.CodeChangeEvt, updateCode)
 			return
 		}
 	}
@@ -132,58 +257,146 @@ func (n *node) Run(eb bus.EventBus, signals <-chan Signal) {
 // TODO : since we included eb we might not need the other channels anymore ?
 func (n *node) codeExec(eb bus.EventBus, codeCancel chan any, code Code, resChan chan bus.NodeOutput, debug bool) {
 	ctx, cancel := context.WithCancel(context.Background())
+	run := n.env.getRun()
+
+	n.mu.Lock()
+	// make node specific data accessible, the neighbor lists are the ones at
+	// the start of the run
+	outNeighborsIds, inNeighborsIds := n.neighborIds()
+	ctx = context.WithValue(ctx, "custom", n.data)
+	ctx = context.WithValue(ctx, "out-neighbors", outNeighborsIds)
+	ctx = context.WithValue(ctx, "in-neighbors", inNeighborsIds)
+	ctx = context.WithValue(ctx, "id", n.id)
+
+	// receive from all incoming connections for as long as the code runs,
+	// calls of other nodes are served once the code registered a handler.
+	// Connections added during the run are served as well.
+	exec := &execution{
+		ctx:       ctx,
+		run:       run,
+		inbox:     make(chan bus.SendTask),
+		server:    newRpcServer(),
+		senders:   make(map[int]*reliableSender),
+		neighbors: newNeighbors(ctx, outNeighborsIds, inNeighborsIds),
+		adversary: newAdversary(n.byzantine),
+	}
+	if n.byzantine.Kind == bus.CodeByzantine {
+		code = Code(n.byzantine.Code)
+	}
+	for _, c := range n.ins {
+		n.serveIn(exec, c)
+	}
+	for _, c := range n.outs {
+		n.serveOut(exec, c)
+	}
+	n.exec = exec
+	n.mu.Unlock()
+
 	go func() {
 		<-codeCancel
+		n.mu.Lock()
+		if n.exec == exec {
+			n.exec = nil
+		}
+		n.mu.Unlock()
 		cancel()
 	}()
 
-	// TODO : stream buffer changes (detected through hashes?) to UI, and should both
-	var userFOut bytes.Buffer
-	i := interp.New(interp.Options{Stdout: &userFOut, Stderr: &userFOut})
+	// errors of fSend are shown after the codes output
+	var sendErrsMu sync.Mutex
+	var sendErrs strings.Builder
+	report := func(err error) {
+		sendErrsMu.Lock()
+		defer sendErrsMu.Unlock()
+		fmt.Fprintln(&sendErrs, "fSend :", err)
+	}
 
-	if err := i.Use(stdlib.Symbols); err != nil {
-		log.Error(err)
-		return
+	fSend := n.getSender(ctx, eb, debug, report, exec)
+	fAwait := n.getAwaiter(ctx, eb, exec.inbox, debug)
+
+	// also accessible through the context e.g. for the sim package
+	ctx = context.WithValue(ctx, "send", fSend)
+	ctx = context.WithValue(ctx, "await", fAwait)
+
+	// request/response between nodes
+	ctx = context.WithValue(ctx, "call", n.getCaller(ctx, eb, debug, run))
+	ctx = context.WithValue(ctx, "handle", exec.server.handle)
+	ctx = context.WithValue(ctx, "serve", exec.server.serve)
+
+	// the current neighbors and notifications once they change
+	ctx = context.WithValue(ctx, "neighbors", exec.neighbors.get)
+	ctx = context.WithValue(ctx, "on-topology-change", exec.neighbors.onChange)
+
+	// Execute the provided function
+	var userRes any
+	var output string
+	var err error
+	backend := n.env.getBackend()
+	external := backend.Kind == bus.ExternalBackend && code == n.env.getCode()
+	var compileErr error
+	switch {
+	case external:
+		// the program does not run the code
+	case backend.Kind == bus.NativeBackend:
+		_, compileErr = n.env.natives.get(code)
+	default:
+		compileErr = n.env.programs.get(code).err
+	}
+	switch {
+	case external:
+		userRes, output, err = n.externalExec(ctx, backend.Command, fSend, fAwait)
+	case compileErr != nil && code == n.env.getCode():
+		// reported once when the code changed, see CodeCompileResultEvt
+		output = "the code does not compile\n"
+	case compileErr != nil:
+		err = compileErr
+	case backend.Kind == bus.ProcessBackend:
+		userRes, output, err = n.processExec(ctx, code, fSend, fAwait)
+	case backend.Kind == bus.NativeBackend:
+		userRes, output, err = n.nativeExec(ctx, code, fSend, fAwait)
+	default:
+		userRes, output, err = interpretExec(ctx, n.env.programs.get(code), fSend, fAwait)
 	}
 
-	_, err := i.Eval(string(code))
+	sendErrsMu.Lock()
+	output += sendErrs.String()
+	sendErrsMu.Unlock()
+
 	if err != nil {
 		log.Error(err)
-		data := bus.NodeOutput{Log: err.Error(), Result: nil, NodeId: n.id}
-		resChan <- data
-		return
+		output += err.Error()
 	}
 
-	v, err := i.Eval("Run")
-	if err != nil {
-		log.Error(err)
-		data := bus.NodeOutput{Log: err.Error(), Result: nil, NodeId: n.id}
-		resChan <- data
-		return
+	// code that was stopped did not finish on its own
+	if ctx.Err() == nil {
+		finishedEvt := bus.Event{Type: bus.NodeFinishedEvt, Data: bus.NodeId(n.id)}
+		eb.Publish(finishedEvt)
 	}
 
-	userF := v.Interface().(func(ctx context.Context, fSend func(targetId int, data any) int, fAwait func(cnt int) []any) any)
+	data := bus.NodeOutput{Log: output, Result: userRes, NodeId: n.id}
+	resChan <- data
+}
 
-	// make node specific data accessible
-	outNeighborsIds := make([]int, len(n.outs))
-	for i, c := range n.outs {
-		outNeighborsIds[i] = c.peer
-	}
-	inNeighborsIds := make([]int, len(n.ins))
-	for i, c := range n.ins {
-		inNeighborsIds[i] = c.peer
+// calls Run of an evaluated interpreter of the program, returns the result and
+// everything the code printed until Run returned. What handlers of calls print
+// afterwards is dropped, since the output of the node was reported already.
+func interpretExec(ctx context.Context, prog *program, fSend sendFunc, fAwait awaitFunc) (userRes any, output string, err error) {
+	// TODO : stream buffer changes (detected through hashes?) to UI, and should both
+	inst, err := prog.instance()
+	if err != nil {
+		return nil, "", err
 	}
-	ctx = context.WithValue(ctx, "custom", n.data)
-	ctx = context.WithValue(ctx, "out-neighbors", outNeighborsIds)
-	ctx = context.WithValue(ctx, "in-neighbors", inNeighborsIds)
-	ctx = context.WithValue(ctx, "id", n.id)
 
-	// Execute the provided function
-	userRes := userF(ctx, n.getSender(ctx, eb, debug), n.getAwaiter(ctx, eb, debug))
-	output := userFOut.String()
+	// a panic in the users code should only affect this node
+	defer func() {
+		if r := recover(); r != nil {
+			output = inst.out.String()
+			err = fmt.Errorf("panic : %v", r)
+		}
+	}()
 
-	data := bus.NodeOutput{Log: output, Result: userRes, NodeId: n.id}
-	resChan <- data
+	userRes = inst.run(ctx, fSend, fAwait)
+	return userRes, inst.out.String(), nil
 }
 
 /*
@@ -196,23 +409,53 @@ func (n *node) codeExec(eb bus.EventBus, codeCancel chan any, code Code, resChan
 // parameter to a specific node
 // TODO : feat : send to all/many
 // TODO : feat : provide equation, send to all that resolve it e.g. for all even id's
-func (n *node) getSender(ctx context.Context, eb bus.EventBus, debug bool) func(targetId int, data any) int {
+// data which can not be sent is reported and reaches no node
+func (n *node) getSender(ctx context.Context, eb bus.EventBus, debug bool, report func(error), exec *execution) sendFunc {
 	return func(targetId int, data any) int {
+		data, err := isolate(n.env.getMessageMode(), data)
+		if err != nil {
+			log.Error(err, "node ", n.id, " could not send")
+			report(err)
+			return 0
+		}
+
+		n.mu.Lock()
+		c, connected := n.outTo(targetId)
+		s, reliable := exec.senders[targetId]
+		n.mu.Unlock()
+
+		// byzantine nodes may send something else or nothing at all
+		deliver := true
+		if connected {
+			data, deliver = exec.adversary.tamper(targetId, data)
+		}
+
 		reachedNodesCnt := 0
-		for _, c := range n.outs {
-			if c.peer == targetId {
-				c.ch <- data
-				reachedNodesCnt++
-				break
+		if connected && !deliver {
+			reachedNodesCnt++
+		} else if connected {
+			msg := newMessage(data)
+			msg.run = exec.run
+			n.env.metrics.sent(n.id, targetId, msg)
+			if reliable {
+				s.send(ctx, msg)
+			} else if n.env.links.drop() {
+				n.env.metrics.lost(n.id, targetId)
+			} else {
+				select {
+				case c.ch <- msg:
+				case <-c.closed:
+					n.env.metrics.lost(n.id, targetId)
+				case <-ctx.Done():
+				}
 			}
+			reachedNodesCnt++
 		}
 
+		// wait for the user to continue after showing the message
 		if debug {
-			sendEvtData := bus.SendTask{From: n.id, To: targetId, Data: data}
-			sendEvt := bus.Event{Type: bus.SentToEvt, Data: sendEvtData}
-			eb.Publish(sendEvt)
-
-			eb.AwaitEvent(ctx, bus.ContinueNodesEvt)
+			sent := bus.SendTask{From: n.id, To: targetId, Data: data}
+			bus.ContinueNodesTopic.AwaitReply(ctx, eb, bus.SentToTopic.Event(sent))
 		}
 
 		return reachedNodesCnt
@@ -221,61 +464,71 @@ func (n *node) getSender(ctx context.Context, eb bus.EventBus, debug bool) func(
 
 // function to be used from user code to wait for n messages from all connected
 // peers
-func (n *node) getAwaiter(ctx context.Context, eb bus.EventBus, debug bool) func(cnt int) []any {
+func (n *node) getAwaiter(ctx context.Context, eb bus.EventBus, inbox <-chan bus.SendTask, debug bool) awaitFunc {
 	return func(cnt int) []any {
 		if debug {
 			awaitStart := bus.Event{Type: bus.AwaitStartEvt, Data: bus.NodeId(n.id)}
 			eb.Publish(awaitStart)
 		}
 
-		log.Debug("Await ", cnt, " from ", len(n.ins), " connections")
-		res, userRes := n.receiveAll(cnt)
+		log.Debug("Node ", n.id, " awaits ", cnt, " messages")
+		start := time.Now()
+		res, userRes := n.receiveAll(ctx, inbox, cnt)
+		n.env.metrics.awaited(n.id, time.Since(start))
 
 		if debug {
-			awaitEnd := bus.Event{Type: bus.AwaitEndEvt, Data: res}
-			eb.Publish(awaitEnd)
-
-			eb.AwaitEvent(ctx, bus.ContinueNodesEvt)
+			bus.ContinueNodesTopic.AwaitReply(ctx, eb, bus.AwaitEndTopic.Event(res))
 		}
 
 		return userRes
 	}
 }
 
-func (n *node) receiveAll(cnt int) ([]bus.SendTask, []any) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	resChan := make(chan bus.SendTask, cnt)
-	defer close(resChan)
-
-	for _, c := range n.ins {
-		go n.receive(ctx, c, resChan)
-	}
-
+// takes cnt messages from the inbox, returns early with less if ctx is done
+func (n *node) receiveAll(ctx context.Context, inbox <-chan bus.SendTask, cnt int) ([]bus.SendTask, []any) {
 	// accumulate results
 	// TODO : I feel like two slices shouldn't be necessary
 	res := make([]bus.SendTask, 0, cnt)
 	userRes := make([]any, 0, cnt)
 	for i := 0; i < cnt; i++ {
-		response := <-resChan
-		res = append(res, response)
-		userRes = append(userRes, response)
+		select {
+		case <-ctx.Done():
+			return res, userRes
+		case response := <-inbox:
+			res = append(res, response)
+			userRes = append(userRes, response)
+		}
 	}
 
 	return res, userRes
 }
 
-func (n *node) receive(ctx context.Context, c connection, res chan bus.SendTask) {
+// forwards messages from a connection to the inbox, one at a time so that
+// messages which are not awaited yet stay in the connection
+func (n *node) receive(ctx context.Context, c connection, r *reliableReceiver, inbox chan<- bus.SendTask) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-c.closed:
+			return
 		case msg := <-c.ch:
-			transmittedData := bus.SendTask{From: c.peer, To: n.id, Data: msg}
-			select {
-			case res <- transmittedData:
-			default: /* channel possibly has been closed */
+			if !n.env.geo.travel(ctx, c.closed, c.peer, n.id, msg.transmitted) {
+				return
+			}
+			deliverable, discarded := r.arrive(msg)
+			if discarded {
+				n.env.metrics.discarded(c.peer, n.id)
+			}
+
+			for _, msg := range deliverable {
+				transmittedData := bus.SendTask{From: c.peer, To: n.id, Data: msg.data}
+				select {
+				case inbox <- transmittedData:
+					n.env.metrics.received(c.peer, n.id, msg)
+				case <-ctx.Done():
+					return
+				}
 			}
 		}
 	}
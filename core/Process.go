@@ -0,0 +1,340 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/nodeproto"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// how long to wait for a spawned process to connect or to return after stop
+const workerTimeout = 10 * time.Second
+
+// accepts the connections of worker processes and hands them to the node that
+// spawned them
+type workerPool struct {
+	mu       sync.Mutex
+	listener net.Listener
+	pending  map[int]chan net.Conn
+	workers  map[*exec.Cmd]<-chan any // running workers, until they exited
+	command  func(addr string, id int) (*exec.Cmd, error)
+}
+
+func newWorkerPool() *workerPool {
+	return &workerPool{
+		pending: make(map[int]chan net.Conn),
+		workers: make(map[*exec.Cmd]<-chan any),
+		command: workerCommand,
+	}
+}
+
+// the emulator itself, started as the worker of a node
+func workerCommand(addr string, id int) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(exe, "-worker", addr, "-worker-id", strconv.Itoa(id)), nil
+}
+
+// requires the lock to be held
+func (p *workerPool) listen() error {
+	if p.listener != nil {
+		return nil
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	p.listener = l
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go p.handshake(conn)
+		}
+	}()
+	return nil
+}
+
+// closes the listener and kills the workers which are still running, returns
+// once they exited. Nodes waiting for their worker to connect give up.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	if p.listener != nil {
+		p.listener.Close()
+		p.listener = nil
+	}
+	for id, ch := range p.pending {
+		close(ch)
+		delete(p.pending, id)
+	}
+	workers := p.workers
+	p.workers = make(map[*exec.Cmd]<-chan any)
+	p.mu.Unlock()
+
+	for cmd, exited := range workers {
+		cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// reads the hello frame to find out which node the connection belongs to
+func (p *workerPool) handshake(conn net.Conn) {
+	c := nodeproto.NewConn(conn, conn)
+	conn.SetReadDeadline(time.Now().Add(workerTimeout))
+	f, err := c.Read()
+	conn.SetReadDeadline(time.Time{})
+	if err != nil || f.Type != nodeproto.Hello {
+		conn.Close()
+		return
+	}
+
+	p.mu.Lock()
+	ch, ok := p.pending[f.Id]
+	delete(p.pending, f.Id)
+	p.mu.Unlock()
+
+	if !ok {
+		conn.Close()
+		return
+	}
+	ch <- conn
+}
+
+// starts a worker process for the node and waits for it to connect, the
+// channel is closed once the worker exited
+func (p *workerPool) spawn(id int) (net.Conn, *exec.Cmd, <-chan any, error) {
+	p.mu.Lock()
+	if err := p.listen(); err != nil {
+		p.mu.Unlock()
+		return nil, nil, nil, err
+	}
+	ch := make(chan net.Conn, 1)
+	p.pending[id] = ch
+	addr := p.listener.Addr().String()
+	p.mu.Unlock()
+
+	cmd, err := p.command(addr, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, nil, nil, err
+	}
+	exited := waitExit(cmd)
+	p.mu.Lock()
+	p.workers[cmd] = exited
+	p.mu.Unlock()
+	go func() {
+		<-exited
+		p.mu.Lock()
+		delete(p.workers, cmd)
+		p.mu.Unlock()
+	}()
+
+	select {
+	case conn, ok := <-ch:
+		if ok {
+			return conn, cmd, exited, nil
+		}
+		err = fmt.Errorf("worker of node %d was stopped before it connected", id)
+	case <-time.After(workerTimeout):
+		err = fmt.Errorf("worker of node %d did not connect", id)
+	}
+	cmd.Process.Kill()
+	<-exited
+	return nil, nil, nil, err
+}
+
+// closed once the process exited, cmd.Wait must not be called elsewhere
+func waitExit(cmd *exec.Cmd) <-chan any {
+	exited := make(chan any)
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	return exited
+}
+
+// runs the code in a child process, messages are serialized to json on their
+// way to and from the child. The child keeps serving calls after it returned,
+// until ctx is done.
+func (n *node) processExec(ctx context.Context, code Code, fSend sendFunc, fAwait awaitFunc) (any, string, error) {
+	conn, cmd, exited, err := n.env.workers.spawn(n.id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c := nodeproto.NewConn(conn, conn)
+	supervise(ctx, c, cmd, exited, conn)
+
+	return n.proxyExec(ctx, c, string(code), fSend, fAwait)
+}
+
+// stops the worker process once ctx is done, killing it if it does not return
+// in time. The closers are closed once it exited.
+func supervise(ctx context.Context, c *nodeproto.Conn, cmd *exec.Cmd, exited <-chan any, closers ...io.Closer) {
+	go func() {
+		select {
+		case <-exited:
+		case <-ctx.Done():
+			c.Write(nodeproto.Frame{Type: nodeproto.Stop})
+			select {
+			case <-exited:
+			case <-time.After(workerTimeout):
+				cmd.Process.Kill()
+			}
+		}
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}()
+}
+
+type proxyResult struct {
+	data   any
+	output string
+	err    error
+}
+
+// serves the requests of a node running outside of the emulator, returns once
+// it returned its result. Requests are served until the connection is closed.
+func (n *node) proxyExec(ctx context.Context, c *nodeproto.Conn, code string, fSend sendFunc, fAwait awaitFunc) (any, string, error) {
+	init := nodeproto.Frame{
+		Type:         nodeproto.Init,
+		Id:           n.id,
+		Custom:       ctx.Value("custom"),
+		OutNeighbors: ctx.Value("out-neighbors").([]int),
+		InNeighbors:  ctx.Value("in-neighbors").([]int),
+		Code:         code,
+	}
+	if err := c.Write(init); err != nil {
+		return nil, "", err
+	}
+
+	// the node follows changes of its neighbors
+	onTopologyChange := ctx.Value("on-topology-change").(onTopologyChangeFunc)
+	onTopologyChange(func(out, in []int) {
+		c.Write(nodeproto.Frame{Type: nodeproto.Topology, OutNeighbors: out, InNeighbors: in})
+	})
+
+	results := make(chan proxyResult, 1)
+	go n.serveProxy(ctx, c, fSend, fAwait, results)
+
+	res := <-results
+	return res.data, res.output, res.err
+}
+
+// the first result or error is passed to results
+func (n *node) serveProxy(ctx context.Context, c *nodeproto.Conn, fSend sendFunc, fAwait awaitFunc, results chan<- proxyResult) {
+	finish := func(res proxyResult) {
+		select {
+		case results <- res:
+		default:
+		}
+	}
+
+	// calls of other nodes forwarded to the process, by sequence number
+	var pendingMu sync.Mutex
+	seq := 0
+	pending := make(map[int]chan nodeproto.Frame)
+	handler := func(from int, request any) (any, error) {
+		reply := make(chan nodeproto.Frame, 1)
+		pendingMu.Lock()
+		seq++
+		req := nodeproto.Frame{Type: nodeproto.Request, Seq: seq, Id: from, Data: request}
+		pending[req.Seq] = reply
+		pendingMu.Unlock()
+
+		if err := c.Write(req); err != nil {
+			pendingMu.Lock()
+			delete(pending, req.Seq)
+			pendingMu.Unlock()
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case res := <-reply:
+			if res.Error != "" {
+				return res.Data, errors.New(res.Error)
+			}
+			return res.Data, nil
+		}
+	}
+
+	var output strings.Builder
+	for {
+		f, err := c.Read()
+		if err != nil {
+			finish(proxyResult{nil, output.String(), err})
+			return
+		}
+
+		switch f.Type {
+		case nodeproto.Send:
+			// requests may block e.g. in debug mode, so serve them concurrently
+			go func(f nodeproto.Frame) {
+				cnt := fSend(f.To, f.Data)
+				c.Write(nodeproto.Frame{Type: nodeproto.Sent, Seq: f.Seq, Cnt: cnt})
+			}(f)
+		case nodeproto.Await:
+			go func(f nodeproto.Frame) {
+				received := fAwait(f.Cnt)
+				msgs := make([]nodeproto.Message, len(received))
+				for i, r := range received {
+					task := r.(bus.SendTask)
+					msgs[i] = nodeproto.Message{From: task.From, To: task.To, Data: task.Data}
+				}
+				c.Write(nodeproto.Frame{Type: nodeproto.Messages, Seq: f.Seq, Messages: msgs})
+			}(f)
+		case nodeproto.Call:
+			go func(f nodeproto.Frame) {
+				fCall := ctx.Value("call").(callFunc)
+				res := nodeproto.Frame{Type: nodeproto.Returned, Seq: f.Seq}
+				data, err := fCall(f.To, f.Data)
+				res.Data = data
+				if err != nil {
+					res.Error = err.Error()
+				}
+				c.Write(res)
+			}(f)
+		case nodeproto.Handle:
+			fServe := ctx.Value("serve").(serveFunc)
+			fServe(handler)
+		case nodeproto.Response:
+			pendingMu.Lock()
+			reply, ok := pending[f.Seq]
+			delete(pending, f.Seq)
+			pendingMu.Unlock()
+			if ok {
+				reply <- f
+			}
+		case nodeproto.Log:
+			output.WriteString(f.Text)
+		case nodeproto.Result:
+			var err error
+			if f.Error != "" {
+				err = errors.New(f.Error)
+			}
+			finish(proxyResult{f.Data, output.String(), err})
+		}
+	}
+}
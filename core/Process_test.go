@@ -0,0 +1,184 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// workerCommand starts the test binary itself, which runs as the worker of a
+// node given these flags as main does
+var workerFlag = flag.String("worker", "", "run as the worker process of a node")
+var workerIdFlag = flag.Int("worker-id", 0, "id of the node the worker process runs")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if *workerFlag != "" {
+		if err := RunWorker(*workerFlag, *workerIdFlag); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const processTestCode = `package main
+
+import (
+	"context"
+	"distributed-sys-emulator/sim"
+	"fmt"
+	"os"
+)
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	if sim.ID(ctx) != 0 {
+		sim.Handle(ctx, func(from int, request any) any {
+			return fmt.Sprint("re ", request)
+		})
+		sim.Send(ctx, 0, "ready")
+		msgs := sim.Await(ctx, 1)
+		if len(msgs) == 0 {
+			return nil
+		}
+		fmt.Print("pid ", os.Getpid())
+		return msgs[0].Data
+	}
+
+	sim.Await(ctx, 1)
+	sim.Send(ctx, 1, "hello")
+	res, err := sim.Call(ctx, 1, 21)
+	fmt.Print("pid ", os.Getpid())
+	return fmt.Sprint(res, " ", err)
+}
+`
+
+func TestNetwork_Process_Backend(t *testing.T) {
+	eb := bus.NewEventbus()
+	finished := make(chan bus.NodeId, 2)
+	eb.AwaitBind(bus.NodeFinishedEvt, func(id bus.NodeId) {
+		finished <- id
+	})
+	outputs := make(chan bus.NodeOutput, 2)
+	eb.AwaitBind(bus.NodeOutputEvt, func(out bus.NodeOutput) {
+		outputs <- out
+	})
+
+	n := newNetwork(2, newNetEnv())
+	n.env.setCode(Code(processTestCode))
+	n.env.setBackend(bus.Backend{Kind: bus.ProcessBackend})
+	n.setAndRunNodes(eb)
+	t.Cleanup(func() { n.emit(TERM) })
+
+	n.mu.Lock()
+	n.connectNodes(0, 1)
+	n.connectNodes(1, 0)
+	n.mu.Unlock()
+
+	n.emit(START)
+	for i := 0; i < 2; i++ {
+		awaitFinished(t, finished)
+	}
+
+	// the workers keep serving calls until they are stopped
+	n.env.workers.mu.Lock()
+	running := len(n.env.workers.workers)
+	n.env.workers.mu.Unlock()
+	if running != 2 {
+		t.Errorf("Expected a running worker for each node, got %d", running)
+	}
+	n.emit(STOP)
+
+	pids := make(map[int]bool)
+	for i := 0; i < 2; i++ {
+		out := <-outputs
+		want := map[int]any{0: "re 21 <nil>", 1: "hello"}[int(out.NodeId)]
+		if out.Result != want {
+			t.Errorf("Expected node %d to return %v, got %v %q", out.NodeId, want, out.Result, out.Log)
+		}
+		pid, err := strconv.Atoi(strings.TrimPrefix(out.Log, "pid "))
+		if err != nil || pid == os.Getpid() {
+			t.Errorf("Expected node %d to run in a child process, got %q", out.NodeId, out.Log)
+		}
+		pids[pid] = true
+	}
+	if len(pids) != 2 {
+		t.Errorf("Expected every node to run in its own process, got %v", pids)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		n.env.workers.mu.Lock()
+		running := len(n.env.workers.workers)
+		n.env.workers.mu.Unlock()
+		if running == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the workers to exit once stopped, %d are still running", running)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWorkerPool_Close(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not available")
+	}
+
+	// the worker never connects
+	p := newWorkerPool()
+	started := make(chan *exec.Cmd, 1)
+	p.command = func(addr string, id int) (*exec.Cmd, error) {
+		cmd := exec.Command(sleep, "30")
+		started <- cmd
+		return cmd, nil
+	}
+	spawned := make(chan error, 1)
+	go func() {
+		_, _, _, err := p.spawn(0)
+		spawned <- err
+	}()
+	cmd := <-started
+
+	// wait for the worker to run
+	var addr string
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		p.mu.Lock()
+		running := len(p.workers)
+		addr = p.listener.Addr().String()
+		p.mu.Unlock()
+		if running == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the worker to be started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p.close()
+	if cmd.ProcessState == nil {
+		t.Error("Expected the worker to be reaped once the pool is closed")
+	}
+	select {
+	case err := <-spawned:
+		if err == nil {
+			t.Error("Expected the spawn to fail once the pool is closed")
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the spawn to give up once the pool is closed")
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("Expected the listener to be closed")
+	}
+}
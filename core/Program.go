@@ -0,0 +1,201 @@
+package core
+
+import (
+	"bytes"
+	"distributed-sys-emulator/sim"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+/* Code is parsed and type checked once per change instead of once per node and
+* run. Every node still compiles the parsed code into an interpreter of its own
+* at the start of a run, so package level variables and the output are not
+* shared between nodes or runs. The interpreters only load the packages the
+* code imports. The sim package is interpreted from its source rather than
+* exported to the interpreter, so that its generic functions can be used.
+ */
+
+// name of the code in positions, e.g. of compile errors
+const sourceName = "code.go"
+
+const simPath = "distributed-sys-emulator/sim"
+
+// the source packages the interpreters may import, below the gopath "."
+var sources fs.FS = dirFS{"src/" + simPath, sim.Files}
+
+// serves the file system as the directory dir, nothing exists outside of it
+type dirFS struct {
+	dir  string
+	fsys fs.FS
+}
+
+func (d dirFS) Open(name string) (fs.File, error) {
+	if name == d.dir {
+		return d.fsys.Open(".")
+	}
+	if rel, ok := strings.CutPrefix(name, d.dir+"/"); ok {
+		return d.fsys.Open(rel)
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+type program struct {
+	code    Code
+	file    *ast.File      // parsed once, shared by the interpreters of all nodes
+	imports interp.Exports // symbols of the packages the code imports
+	err     error          // the code does not compile, nodes do not run
+}
+
+// an evaluated interpreter for a single node and run
+type instance struct {
+	run runFunc
+	out *output // everything the code printed
+}
+
+// the output of an instance, handlers of calls print concurrently to Run
+type output struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+func compileProgram(code Code) *program {
+	p := &program{code: code}
+
+	f, err := parser.ParseFile(token.NewFileSet(), sourceName, string(code), parser.DeclarationErrors)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	p.file = f
+	imported := make(map[string]bool)
+	addImports(imported, f)
+	if imported[simPath] {
+		simFile, err := parser.ParseFile(token.NewFileSet(), "sim.go", sim.Source, parser.ImportsOnly)
+		if err != nil {
+			p.err = err
+			return p
+		}
+		addImports(imported, simFile)
+	}
+	p.imports = make(interp.Exports)
+	for key, pkg := range stdlib.Symbols {
+		// keys are the import path followed by the package name, except for
+		// "." which the interpreter always needs
+		slash := strings.LastIndex(key, "/")
+		if slash < 0 || imported[key[:slash]] {
+			p.imports[key] = pkg
+		}
+	}
+
+	// type checking requires a compilation, whose errors are the same for
+	// every node
+	if _, err := p.instance(); err != nil {
+		p.err = err
+	}
+	return p
+}
+
+func addImports(imported map[string]bool, f *ast.File) {
+	for _, imp := range f.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		imported[path] = true
+	}
+}
+
+// compiles the parsed code into a fresh interpreter and evaluates it
+func (p *program) instance() (inst *instance, err error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	// package level initializers run here
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic : %v", r)
+		}
+	}()
+
+	out := &output{}
+	i := interp.New(interp.Options{Stdout: out, Stderr: out, GoPath: ".", SourcecodeFilesystem: sources})
+	if err := i.Use(p.imports); err != nil {
+		return nil, err
+	}
+	// the file was parsed as the first of its file set, registering it as the
+	// first file of the interpreter lets positions refer to the code
+	i.FileSet().AddFile(sourceName, -1, len(p.code)).SetLinesForContent([]byte(p.code))
+	compiled, err := i.CompileAST(p.file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := i.Execute(compiled); err != nil {
+		return nil, err
+	}
+
+	v, err := i.Eval("Run")
+	if err != nil {
+		return nil, err
+	}
+	run, ok := v.Interface().(runFunc)
+	if !ok {
+		return nil, errors.New("the Run function does not match the required signature")
+	}
+	return &instance{run: run, out: out}, nil
+}
+
+// compiled programs by their code, the nodes code and that of byzantine nodes
+type programs struct {
+	mu     sync.Mutex
+	byCode map[Code]*program
+}
+
+func newPrograms() *programs {
+	return &programs{byCode: make(map[Code]*program)}
+}
+
+// compiles the code on first use
+func (ps *programs) get(code Code) *program {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	p, ok := ps.byCode[code]
+	if !ok {
+		p = compileProgram(code)
+		ps.byCode[code] = p
+	}
+	return p
+}
+
+// compiles changed code, the programs of other code are dropped
+func (ps *programs) replace(code Code) *program {
+	ps.mu.Lock()
+	p, ok := ps.byCode[code]
+	ps.byCode = make(map[Code]*program)
+	if ok {
+		ps.byCode[code] = p
+	}
+	ps.mu.Unlock()
+
+	return ps.get(code)
+}
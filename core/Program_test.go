@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"distributed-sys-emulator/bus"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const counterTestCode = `package main
+
+import (
+	"context"
+	"fmt"
+	"distributed-sys-emulator/sim"
+)
+
+var runs int
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	runs++
+	fmt.Print("node ", sim.ID(ctx))
+	return runs
+}
+`
+
+func TestProgram_Instances_Are_Isolated(t *testing.T) {
+	prog := compileProgram(Code(counterTestCode))
+	if prog.err != nil {
+		t.Fatal(prog.err)
+	}
+
+	// package level variables and the output are not shared
+	for i := 0; i < 5; i++ {
+		ctx := context.WithValue(context.Background(), "id", i)
+		res, output, err := interpretExec(ctx, prog, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != 1 {
+			t.Errorf("Expected every instance to start with fresh variables, got %v", res)
+		}
+		if output != "node "+strconv.Itoa(i) {
+			t.Errorf("Expected the output of node %d only, got %q", i, output)
+		}
+	}
+}
+
+const customTestCode = `package main
+
+import (
+	"context"
+	"distributed-sys-emulator/sim"
+)
+
+type config struct {
+	Value int ` + "`json:\"value\"`" + `
+}
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	c, err := sim.Custom[config](ctx)
+	if err != nil {
+		return err.Error()
+	}
+	return c.Value + sim.ID(ctx)
+}
+`
+
+func TestProgram_Generic_Sim(t *testing.T) {
+	prog := compileProgram(Code(customTestCode))
+	if prog.err != nil {
+		t.Fatal(prog.err)
+	}
+
+	ctx := context.WithValue(context.Background(), "id", 2)
+	ctx = context.WithValue(ctx, "custom", map[string]any{"value": 40.})
+	res, _, err := interpretExec(ctx, prog, nil, nil)
+	if err != nil || res != 42 {
+		t.Errorf("Expected the custom data to be decoded by interpreted code, got %v, %v", res, err)
+	}
+}
+
+func TestProgram_Compile_Error(t *testing.T) {
+	for _, code := range []string{
+		"package main\n\nfunc Run(",
+		"package main\n\nfunc Run() {}\n",
+		"package main\n\nimport \"not/a/package\"\n",
+	} {
+		prog := compileProgram(Code(code))
+		if prog.err == nil {
+			t.Errorf("Expected an error for %q", code)
+		}
+		if _, err := prog.instance(); err == nil || err.Error() != prog.err.Error() {
+			t.Errorf("Expected the compile error, got %v", err)
+		}
+	}
+
+	// type errors refer to the line in the code
+	prog := compileProgram(Code("package main\n\nfunc Run() {\n\tundefined()\n}\n"))
+	if prog.err == nil || !strings.Contains(prog.err.Error(), sourceName+":4:") {
+		t.Errorf("Expected the error at line 4, got %v", prog.err)
+	}
+}
+
+func TestNetwork_Compile_Error_Reported_Once(t *testing.T) {
+	eb := bus.NewEventbus()
+	n := newNetwork(3, newNetEnv())
+
+	results := make(chan bus.CodeCompileResult, 1)
+	eb.AwaitBind(bus.CodeCompileResultEvt, func(res bus.CodeCompileResult) {
+		results <- res
+	})
+	code := Code("package main\n\nfunc Run(")
+	n.env.setCode(code)
+	n.compile(eb, code)
+	if res := <-results; res.Err == "" {
+		t.Error("Expected a compile error")
+	}
+
+	outputs := make(chan bus.NodeOutput, 3)
+	eb.AwaitBind(bus.NodeOutputEvt, func(out bus.NodeOutput) {
+		outputs <- out
+	})
+	n.setAndRunNodes(eb)
+	t.Cleanup(func() { n.emit(TERM) })
+	n.emit(START)
+	time.Sleep(50 * time.Millisecond)
+	n.emit(STOP)
+
+	for i := 0; i < 3; i++ {
+		out := <-outputs
+		if !strings.HasPrefix(out.Log, "the code does not compile") {
+			t.Errorf("Expected a short note instead of the error, got %q", out.Log)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// default time after which an unacknowledged message is sent again
+const retransmitTimeout = 100 * time.Millisecond
+
+// delivers the current time every interval until stopped, replaced in tests to
+// drive the retransmissions
+type tickSource func(interval time.Duration) (ticks <-chan time.Time, stop func())
+
+func realTicks(interval time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+// the reliability of every connection, connections without their own setting
+// use the default
+type reliabilities struct {
+	mu    sync.Mutex
+	def   bus.Reliability
+	edges map[bus.Connection]bus.Reliability
+}
+
+func newReliabilities() *reliabilities {
+	return &reliabilities{
+		def:   bus.Reliability{Kind: bus.Unreliable},
+		edges: make(map[bus.Connection]bus.Reliability),
+	}
+}
+
+func (r *reliabilities) configure(config bus.Reliability) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(config.Connections) == 0 {
+		r.def = config
+		return
+	}
+	for _, c := range config.Connections {
+		r.edges[c] = config
+	}
+}
+
+func (r *reliabilities) get(from, to int) bus.Reliability {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, ok := r.edges[bus.Connection{From: from, To: to}]
+	if !ok {
+		res = r.def
+	}
+	if res.Kind == "" {
+		res.Kind = bus.Unreliable
+	}
+	if res.Timeout <= 0 {
+		res.Timeout = retransmitTimeout
+	}
+	return res
+}
+
+// sending side of a reliable connection for the duration of a run. Messages
+// are numbered and kept until they are acknowledged, otherwise they are sent
+// again after the timeout.
+//
+// Acknowledgements are simulated rather than sent, no message travels back on
+// the reverse edge, which may not even exist. A message counts as acknowledged
+// once it arrived in the connection, unless a second roll of the links loss
+// drops the acknowledgement.
+type reliableSender struct {
+	from, to int
+	ch       chan message
+	closed   chan any
+	env      *netEnv
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	seq     int
+	unacked map[int]message
+}
+
+func newReliableSender(ctx context.Context, from int, c connection, env *netEnv, timeout time.Duration) *reliableSender {
+	s := &reliableSender{
+		from:    from,
+		to:      c.peer,
+		ch:      c.ch,
+		closed:  c.closed,
+		env:     env,
+		timeout: timeout,
+		unacked: make(map[int]message),
+	}
+	go s.retransmit(ctx)
+	return s
+}
+
+func (s *reliableSender) send(ctx context.Context, msg message) {
+	s.mu.Lock()
+	s.seq++
+	msg.seq = s.seq
+	s.unacked[msg.seq] = msg
+	s.mu.Unlock()
+
+	s.transmit(ctx, msg)
+}
+
+func (s *reliableSender) transmit(ctx context.Context, msg message) {
+	if s.env.links.drop() {
+		s.env.metrics.lost(s.from, s.to)
+		return
+	}
+
+	select {
+	case s.ch <- msg:
+	case <-s.closed:
+		s.env.metrics.lost(s.from, s.to)
+		return
+	case <-ctx.Done():
+		return
+	}
+
+	// the simulated acknowledgement is lost like a message would be
+	if !s.env.links.drop() {
+		s.mu.Lock()
+		delete(s.unacked, msg.seq)
+		s.mu.Unlock()
+	}
+}
+
+// periodically sends the unacknowledged messages again, oldest first, until
+// the run ends or the nodes are disconnected
+func (s *reliableSender) retransmit(ctx context.Context) {
+	ticks, stop := s.env.getTicks()(s.timeout)
+	defer stop()
+
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.closed:
+			return
+		case now = <-ticks:
+		}
+
+		s.mu.Lock()
+		var due []message
+		for _, msg := range s.unacked {
+			if now.Sub(msg.transmitted) >= s.timeout {
+				due = append(due, msg)
+			}
+		}
+		s.mu.Unlock()
+
+		sort.Slice(due, func(i, j int) bool {
+			return due[i].seq < due[j].seq
+		})
+		for _, msg := range due {
+			msg.transmitted = now
+			s.mu.Lock()
+			if _, ok := s.unacked[msg.seq]; ok {
+				s.unacked[msg.seq] = msg
+			}
+			s.mu.Unlock()
+
+			s.env.metrics.retransmitted(s.from, s.to, msg)
+			s.transmit(ctx, msg)
+		}
+	}
+}
+
+// receiving side of a connection for the duration of a run, decides which of
+// the arriving messages are delivered to the node and in which order
+type reliableReceiver struct {
+	kind     bus.ReliabilityKind
+	run      int
+	expected int             // next sequence number to deliver, exactly once only
+	pending  map[int]message // arrived ahead of expected
+}
+
+func newReliableReceiver(kind bus.ReliabilityKind, run int) *reliableReceiver {
+	return &reliableReceiver{kind: kind, run: run, expected: 1, pending: make(map[int]message)}
+}
+
+// returns the messages to deliver once msg arrived, and whether msg was
+// discarded as a duplicate or a leftover of a previous run
+func (r *reliableReceiver) arrive(msg message) ([]message, bool) {
+	if r.kind == bus.Unreliable || msg.seq == 0 {
+		return []message{msg}, false
+	}
+	if msg.run != r.run {
+		return nil, true
+	}
+	if r.kind == bus.AtLeastOnce {
+		return []message{msg}, false
+	}
+
+	if _, ok := r.pending[msg.seq]; ok || msg.seq < r.expected {
+		return nil, true
+	}
+	r.pending[msg.seq] = msg
+
+	var res []message
+	for {
+		next, ok := r.pending[r.expected]
+		if !ok {
+			return res, false
+		}
+		delete(r.pending, r.expected)
+		res = append(res, next)
+		r.expected++
+	}
+}
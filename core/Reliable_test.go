@@ -0,0 +1,170 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestReliableReceiver_Exactly_Once_In_Order(t *testing.T) {
+	r := newReliableReceiver(bus.ExactlyOnce, 1)
+
+	var delivered []int
+	for _, seq := range []int{2, 1, 1, 3, 2, 5, 4} {
+		msgs, _ := r.arrive(message{data: seq, run: 1, seq: seq})
+		for _, m := range msgs {
+			delivered = append(delivered, m.data.(int))
+		}
+	}
+
+	if len(delivered) != 5 {
+		t.Fatalf("Expected 5 messages to be delivered, got %v", delivered)
+	}
+	for i, d := range delivered {
+		if d != i+1 {
+			t.Errorf("Expected message %d at position %d, got %d", i+1, i, d)
+		}
+	}
+}
+
+func TestReliableReceiver_Discards_Previous_Runs(t *testing.T) {
+	r := newReliableReceiver(bus.AtLeastOnce, 2)
+
+	msgs, discarded := r.arrive(message{data: 1, run: 1, seq: 1})
+	if len(msgs) != 0 || !discarded {
+		t.Error("Messages of a previous run should be discarded")
+	}
+
+	msgs, _ = r.arrive(message{data: 1, run: 2, seq: 1})
+	msgs2, _ := r.arrive(message{data: 1, run: 2, seq: 1})
+	if len(msgs)+len(msgs2) != 2 {
+		t.Error("At least once should deliver duplicates")
+	}
+}
+
+// a sender from node 0 to 1 whose retransmissions are driven by the returned
+// channel, stopped is closed once the sender gave up
+func testSender(ctx context.Context) (s *reliableSender, c connection, ticks chan time.Time, stopped chan any) {
+	ticks = make(chan time.Time)
+	stopped = make(chan any)
+	env := newNetEnv()
+	env.setTicks(func(time.Duration) (<-chan time.Time, func()) {
+		return ticks, func() { close(stopped) }
+	})
+	c = connection{peer: 1, link: newLink()}
+	return newReliableSender(ctx, 0, c, env, retransmitTimeout), c, ticks, stopped
+}
+
+// the lost and retransmitted messages from node 0 to 1
+func senderStats(s *reliableSender) (lost, retransmits int) {
+	for _, e := range s.env.metrics.snapshot(false).Edges {
+		if e.From == 0 && e.To == 1 {
+			return e.Lost, e.Retransmits
+		}
+	}
+	return 0, 0
+}
+
+// sends a tick and waits until the sender is done with it, by handing it the
+// next one
+func tick(t *testing.T, ticks chan time.Time, now time.Time) {
+	for i := 0; i < 2; i++ {
+		select {
+		case ticks <- now:
+		case <-time.After(time.Second):
+			t.Fatal("Sender did not wait for the next tick")
+		}
+	}
+}
+
+func TestReliableSender_Retransmits_Lost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, c, ticks, _ := testSender(ctx)
+
+	s.env.links.configure(bus.LinkConfig{Loss: 1})
+	msg := newMessage("a")
+	s.send(ctx, msg)
+	if len(c.ch) != 0 {
+		t.Fatal("Expected the message to get lost")
+	}
+
+	// not sent again before the timeout
+	s.env.links.configure(bus.LinkConfig{Loss: 0})
+	tick(t, ticks, msg.transmitted.Add(retransmitTimeout/2))
+	if len(c.ch) != 0 {
+		t.Error("Expected no retransmission before the timeout")
+	}
+
+	tick(t, ticks, msg.transmitted.Add(retransmitTimeout))
+	if len(c.ch) != 1 {
+		t.Fatalf("Expected the message to be sent again, got %d", len(c.ch))
+	}
+	if again := <-c.ch; again.data != "a" || again.seq != 1 {
+		t.Errorf("Expected the first message to be sent again, got %v", again)
+	}
+	if lost, retransmits := senderStats(s); lost != 1 || retransmits != 1 {
+		t.Errorf("Expected 1 lost and 1 retransmitted message, got %d and %d", lost, retransmits)
+	}
+
+	// the retransmission arrived and was acknowledged
+	tick(t, ticks, msg.transmitted.Add(10*retransmitTimeout))
+	if len(c.ch) != 0 {
+		t.Error("Expected an acknowledged message not to be sent again")
+	}
+}
+
+func TestReliableSender_Acknowledged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, c, ticks, _ := testSender(ctx)
+
+	first, second := newMessage(1), newMessage(2)
+	s.send(ctx, first)
+	s.send(ctx, second)
+	if len(c.ch) != 2 {
+		t.Fatalf("Expected both messages to be sent, got %d", len(c.ch))
+	}
+	if a, b := <-c.ch, <-c.ch; a.seq != 1 || b.seq != 2 {
+		t.Errorf("Expected the messages to be numbered in order, got %d and %d", a.seq, b.seq)
+	}
+
+	tick(t, ticks, second.transmitted.Add(10*retransmitTimeout))
+	if len(c.ch) != 0 {
+		t.Error("Expected acknowledged messages not to be sent again")
+	}
+	if _, retransmits := senderStats(s); retransmits != 0 {
+		t.Errorf("Expected no retransmissions, got %d", retransmits)
+	}
+}
+
+func TestReliableSender_Gives_Up(t *testing.T) {
+	// once the nodes are disconnected
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, c, _, stopped := testSender(ctx)
+
+	s.env.links.configure(bus.LinkConfig{Loss: 1})
+	s.send(ctx, newMessage("a"))
+	close(c.closed)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the sender to stop retransmitting once disconnected")
+	}
+	if lost, retransmits := senderStats(s); lost != 1 || retransmits != 0 {
+		t.Errorf("Expected 1 lost message and no retransmissions, got %d and %d", lost, retransmits)
+	}
+
+	// once the run ended
+	ctx, cancel = context.WithCancel(context.Background())
+	_, _, _, stopped = testSender(ctx)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the sender to stop retransmitting once the run ended")
+	}
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// how long fCall waits for a response, unless configured otherwise
+const defaultCallTimeout = 5 * time.Second
+
+type callFunc = func(targetId int, request any) (any, error)
+type handlerFunc = func(from int, request any) any
+type handleFunc = func(handler handlerFunc)
+
+// answers a call, an error fails it. Handlers of the user code report errors
+// by panicking instead, see callHandler.
+type rpcHandler = func(from int, request any) (any, error)
+type serveFunc = func(handler rpcHandler)
+
+// attached to the messages of a call
+type rpcCall struct {
+	id    int
+	reply chan rpcResult // buffered, only the first response is kept
+}
+
+type rpcResult struct {
+	msg message
+	err error
+}
+
+// serves the calls of other nodes for the duration of a run
+type rpcServer struct {
+	mu         sync.Mutex
+	handler    rpcHandler
+	registered chan any // closed once a handler is registered
+}
+
+func newRpcServer() *rpcServer {
+	return &rpcServer{registered: make(chan any)}
+}
+
+// function to be used from user code (via the "handle" ctx key) to register
+// the handler of incoming calls, replaces the previous one
+func (s *rpcServer) handle(handler handlerFunc) {
+	s.serve(func(from int, request any) (any, error) {
+		return callHandler(handler, from, request)
+	})
+}
+
+// registers a handler that reports errors, e.g. of a node running outside of
+// the emulator (via the "serve" ctx key)
+func (s *rpcServer) serve(handler rpcHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.handler == nil {
+		close(s.registered)
+	}
+	s.handler = handler
+}
+
+// waits until a handler is registered, so calls that arrive early are not lost
+func (s *rpcServer) getHandler(ctx context.Context) (rpcHandler, bool) {
+	select {
+	case <-ctx.Done():
+		return nil, false
+	case <-s.registered:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.handler, true
+}
+
+// function to be used from user code (via the "call" ctx key) to send a
+// request to a specific node and wait for its response
+func (n *node) getCaller(ctx context.Context, eb bus.EventBus, debug bool, run int) callFunc {
+	return func(targetId int, request any) (any, error) {
+		data, err := isolate(n.env.getMessageMode(), request)
+		if err != nil {
+			return nil, err
+		}
+
+		n.mu.Lock()
+		c, connected := n.outTo(targetId)
+		n.mu.Unlock()
+		if !connected {
+			return nil, fmt.Errorf("node %d is not connected to node %d", n.id, targetId)
+		}
+
+		id := n.env.nextCall()
+		msg := newMessage(data)
+		msg.run = run
+		msg.call = &rpcCall{id: id, reply: make(chan rpcResult, 1)}
+		n.env.metrics.sent(n.id, targetId, msg)
+
+		if debug {
+			callData := bus.Call{Id: id, From: n.id, To: targetId, Data: data}
+			bus.ContinueNodesTopic.AwaitReply(ctx, eb, bus.CallTopic.Event(callData))
+		}
+
+		// calls over reliable connections are not lost
+		reliable := n.env.reliabilities.get(n.id, targetId).Kind != bus.Unreliable
+		if !reliable && n.env.links.drop() {
+			n.env.metrics.lost(n.id, targetId)
+		} else {
+			select {
+			case c.calls <- msg:
+			case <-c.closed:
+				n.env.metrics.lost(n.id, targetId)
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(n.env.getCallTimeout()):
+			return nil, fmt.Errorf("call %d to node %d timed out", id, targetId)
+		case res := <-msg.call.reply:
+			n.env.metrics.received(targetId, n.id, res.msg)
+
+			if debug {
+				returnData := bus.Call{Id: id, From: targetId, To: n.id, Data: res.msg.data}
+				bus.ContinueNodesTopic.AwaitReply(ctx, eb, bus.CallReturnTopic.Event(returnData))
+			}
+
+			return res.msg.data, res.err
+		}
+	}
+}
+
+// answers the calls arriving over a connection, each one concurrently
+func (n *node) serveCalls(ctx context.Context, c connection, server *rpcServer, run int) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.closed:
+			return
+		case msg := <-c.calls:
+			// the caller of a previous run is not waiting anymore
+			if msg.run != run {
+				n.env.metrics.discarded(c.peer, n.id)
+				continue
+			}
+			go n.answer(ctx, c.peer, msg, server)
+		}
+	}
+}
+
+func (n *node) answer(ctx context.Context, from int, req message, server *rpcServer) {
+	if !n.env.geo.travel(ctx, nil, from, n.id, req.transmitted) {
+		return
+	}
+	handler, ok := server.getHandler(ctx)
+	if !ok {
+		return
+	}
+	n.env.metrics.received(from, n.id, req)
+
+	resp, err := handler(from, req.data)
+	if err == nil {
+		resp, err = isolate(n.env.getMessageMode(), resp)
+	}
+
+	res := newMessage(resp)
+	n.env.metrics.sent(n.id, from, res)
+	reliable := n.env.reliabilities.get(from, n.id).Kind != bus.Unreliable
+	if !reliable && n.env.links.drop() {
+		n.env.metrics.lost(n.id, from)
+		return
+	}
+	if !n.env.geo.travel(ctx, nil, n.id, from, res.transmitted) {
+		return
+	}
+
+	select {
+	case req.call.reply <- rpcResult{res, err}:
+	default:
+		n.env.metrics.discarded(n.id, from)
+	}
+}
+
+// a panic in the handler fails the call instead of the node
+func callHandler(handler handlerFunc, from int, request any) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic : %v", r)
+		}
+	}()
+	return handler(from, request), nil
+}
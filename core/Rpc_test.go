@@ -0,0 +1,99 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rpcTestCode = `package main
+
+import (
+	"context"
+	"distributed-sys-emulator/sim"
+	"fmt"
+	"time"
+)
+
+func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+	// node 1 doubles requests, fails negative ones and is too slow for zero
+	if sim.ID(ctx) != 0 {
+		sim.Handle(ctx, func(from int, request any) any {
+			fmt.Println("request", request)
+			n := request.(int)
+			if n < 0 {
+				panic("negative request")
+			}
+			if n == 0 {
+				time.Sleep(time.Second)
+			}
+			return n * 2
+		})
+		// what the handler prints while Run runs is part of the output
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	}
+
+	doubled, err := sim.Call(ctx, 1, 21)
+	_, failed := sim.Call(ctx, 1, -1)
+	_, timedOut := sim.Call(ctx, 1, 0)
+	_, unconnected := sim.Call(ctx, 0, 1)
+	return fmt.Sprintf("%v %v | %v | %v | %v", doubled, err, failed, timedOut, unconnected)
+}
+`
+
+func TestNetwork_Call(t *testing.T) {
+	eb := bus.NewEventbus()
+	finished := make(chan bus.NodeId, 2)
+	eb.AwaitBind(bus.NodeFinishedEvt, func(id bus.NodeId) {
+		finished <- id
+	})
+	outputs := make(chan bus.NodeOutput, 2)
+	eb.AwaitBind(bus.NodeOutputEvt, func(out bus.NodeOutput) {
+		outputs <- out
+	})
+
+	n := newNetwork(2, newNetEnv())
+	n.env.setCode(Code(rpcTestCode))
+	n.env.setCallTimeout(100 * time.Millisecond)
+	n.setAndRunNodes(eb)
+	t.Cleanup(func() { n.emit(TERM) })
+	n.mu.Lock()
+	n.connectNodes(0, 1)
+	n.mu.Unlock()
+
+	start := time.Now()
+	n.emit(START)
+	awaitFinished(t, finished)
+	awaitFinished(t, finished)
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("Expected the slow call to time out after 100ms, took %v", elapsed)
+	}
+	n.emit(STOP)
+
+	for i := 0; i < 2; i++ {
+		out := <-outputs
+		if out.NodeId != 0 {
+			if !strings.Contains(out.Log, "request 21") {
+				t.Errorf("Expected the output of the handler, got %q", out.Log)
+			}
+			continue
+		}
+		expected := "42 <nil> | handler panic : negative request | call 3 to node 1 timed out | node 0 is not connected to node 0"
+		if out.Result != expected {
+			t.Errorf("Expected %q, got %v %q", expected, out.Result, out.Log)
+		}
+	}
+
+	// every call is counted, the unconnected one never left the node
+	requests := 0
+	for _, e := range n.env.metrics.snapshot(false).Edges {
+		if e.From == 0 && e.To == 1 {
+			requests = e.Messages
+		}
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests from node 0 to 1, got %d", requests)
+	}
+}
@@ -0,0 +1,279 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+/* Generates the custom data of every node from a template, which is a go
+* expression that may use json-like object and array literals e.g.
+*
+*   {"value": rand(0, 100), "isLeader": id == 0, "peers": [id - 1, id + 1]}
+*
+* The following are available within the expression :
+*   id          the nodes id
+*   n           the number of nodes
+*   rand(a, b)  a random int in [a, b]
+*   randf()     a random float64 in [0, 1)
+*   choice(...) one of the arguments at random
+*   null        nil
+*   fmt, math, strings
+*
+* The random numbers depend on the seed only, so the same seed generates the
+* same data.
+ */
+
+type templateFunc = func(id, n int, rand func(int, int) int, randf func() float64, choice func(...any) any) any
+
+const templatePrefix = `package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+var _, _, _ = fmt.Sprint, math.Abs, strings.Repeat
+
+func Template(id, n int, rand func(int, int) int, randf func() float64, choice func(...any) any) any {
+	var null any
+	_ = null
+	return `
+
+// GenerateCustom evaluates the template for every node, the results are
+// normalized as if they were parsed from json
+func GenerateCustom(template string, cnt int, seed int64) ([]any, error) {
+	expr, origin, err := translateTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	i := interp.New(interp.Options{})
+	if err := i.Use(stdlib.Symbols); err != nil {
+		return nil, err
+	}
+	if err := compileTemplate(i, templatePrefix+expr+"\n}\n"); err != nil {
+		return nil, templateError(err, template, origin)
+	}
+	v, err := i.Eval("Template")
+	if err != nil {
+		return nil, err
+	}
+	f, ok := v.Interface().(templateFunc)
+	if !ok {
+		return nil, errors.New("invalid template")
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	randInt := func(min, max int) int {
+		if max < min {
+			return min
+		}
+		return min + rng.Intn(max-min+1)
+	}
+	choice := func(values ...any) any {
+		if len(values) == 0 {
+			return nil
+		}
+		return values[rng.Intn(len(values))]
+	}
+
+	res := make([]any, cnt)
+	for id := range res {
+		data, err := evalTemplate(f, id, cnt, randInt, rng.Float64, choice)
+		if err != nil {
+			return nil, fmt.Errorf("node %d : %w", id, err)
+		}
+		res[id] = data
+	}
+	return res, nil
+}
+
+// yaegi panics on some invalid templates instead of returning an error
+func compileTemplate(i *interp.Interpreter, src string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	_, err = i.Eval(src)
+	return err
+}
+
+func evalTemplate(f templateFunc, id, cnt int, randInt func(int, int) int, randf func() float64, choice func(...any) any) (res any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic : %v", r)
+		}
+	}()
+
+	data := f(id, cnt, randInt, randf, choice)
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &res)
+	return res, err
+}
+
+type templateToken struct {
+	offset int
+	tok    token.Token
+	lit    string
+}
+
+// turns the json-like literals of a template into go composite literals,
+// { becomes map[string]any{ where an operand starts and [ becomes []any{
+// unless it indexes a value, follows map or starts the type of a slice or
+// array literal such as []int{1}. Other braces such as those of func
+// literals, blocks or typed composite literals are kept. The origin of every
+// byte of the result is its offset in the template.
+func translateTemplate(template string) (expr string, origin []int, err error) {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("template", fset.Base(), len(template))
+
+	var errs scanner.ErrorList
+	s.Init(file, []byte(template), func(pos token.Position, msg string) {
+		errs.Add(pos, msg)
+	}, 0)
+
+	var toks []templateToken
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		// everything is joined into a single line, so json-like literals
+		// don't need a trailing comma before a line break
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		toks = append(toks, templateToken{file.Offset(pos), tok, tokenText(tok, lit)})
+	}
+	if errs.Len() > 0 {
+		return "", nil, fmt.Errorf("invalid template : %w", errs.Err())
+	}
+
+	// the closing ] of every [
+	closing := make(map[int]int)
+	var open []int
+	for i, t := range toks {
+		switch {
+		case t.tok == token.LBRACK:
+			open = append(open, i)
+		case t.tok == token.RBRACK && len(open) > 0:
+			closing[open[len(open)-1]] = i
+			open = open[:len(open)-1]
+		}
+	}
+
+	var b strings.Builder
+	write := func(s string, offset int, copied bool) {
+		for i := range s {
+			if copied {
+				origin = append(origin, offset+i)
+			} else {
+				origin = append(origin, offset)
+			}
+		}
+		b.WriteString(s)
+	}
+
+	literals := make(map[int]bool) // the ] that close a literal
+	prev := token.ILLEGAL
+	last := 0
+	for i, t := range toks {
+		if t.offset > last {
+			write(" ", t.offset, false)
+		}
+
+		// indexing follows an operand, a literal follows anything else
+		indexes := prev == token.IDENT || prev == token.RPAREN || prev == token.RBRACK ||
+			prev == token.RBRACE || prev == token.STRING || prev == token.INT || prev == token.FLOAT
+
+		// an operand starts at the beginning, after return or after an
+		// operator, a block or the literal of a type follows anything else
+		operand := prev == token.ILLEGAL || prev == token.RETURN || prev.IsOperator() &&
+			prev != token.RPAREN && prev != token.RBRACK && prev != token.RBRACE && prev != token.SEMICOLON
+
+		switch {
+		case t.tok == token.LBRACE && operand:
+			write("map[string]any{", t.offset, false)
+		case t.tok == token.LBRACK && !indexes && prev != token.MAP && !startsType(toks, i, closing):
+			write("[]any{", t.offset, false)
+			literals[closing[i]] = true
+		case t.tok == token.RBRACK && literals[i]:
+			write("}", t.offset, false)
+		default:
+			write(t.lit, t.offset, true)
+		}
+
+		last = t.offset + len(t.lit)
+		prev = t.tok
+	}
+	return b.String(), origin, nil
+}
+
+// whether the [ at i starts the type of a slice or array, which is the case
+// when its ] is followed by the element type
+func startsType(toks []templateToken, i int, closing map[int]int) bool {
+	end, ok := closing[i]
+	if !ok || end+1 >= len(toks) {
+		return false
+	}
+	switch toks[end+1].tok {
+	case token.IDENT, token.MAP, token.STRUCT, token.FUNC, token.INTERFACE, token.CHAN:
+		return true
+	case token.LBRACK, token.MUL:
+		return end == i+1
+	}
+	return false
+}
+
+func tokenText(tok token.Token, lit string) string {
+	if lit != "" {
+		return lit
+	}
+	return tok.String()
+}
+
+// the position of an error in the wrapper of the template e.g. _.go:14:33
+var wrapperPos = regexp.MustCompile(`(?:_\.go:)?\b(\d+):(\d+): `)
+
+// rewrites the positions of a compile error in the wrapper into line:col in
+// the template, positions outside of the template are dropped
+func templateError(err error, template string, origin []int) error {
+	exprLine := strings.Count(templatePrefix, "\n") + 1
+	exprCol := len(templatePrefix) - strings.LastIndex(templatePrefix, "\n")
+
+	msg := wrapperPos.ReplaceAllStringFunc(err.Error(), func(s string) string {
+		m := wrapperPos.FindStringSubmatch(s)
+		line, _ := strconv.Atoi(m[1])
+		col, _ := strconv.Atoi(m[2])
+		offset := col - exprCol
+		if line != exprLine || offset < 0 || offset > len(origin) {
+			return ""
+		}
+
+		pos := len(template)
+		if offset < len(origin) {
+			pos = origin[offset]
+		}
+		line = strings.Count(template[:pos], "\n") + 1
+		col = pos - strings.LastIndex(template[:pos], "\n")
+		return fmt.Sprintf("%d:%d: ", line, col)
+	})
+	return fmt.Errorf("invalid template : %s", msg)
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateCustom(t *testing.T) {
+	template := `{
+		"value": rand(0, 100),
+		"isLeader": id == 0,
+		"peers": [(id + n - 1) % n, (id + 1) % n],
+		"name": fmt.Sprintf("node-%d", id),
+		"second": [10, 20, 30][1], // indexes a literal
+		"none": null,
+		"role": func() any { if id == 0 { return {"leader": true} }; return null }(),
+		"point": struct{ X, Y int }{id, -id},
+		"counts": map[string]int{"x": 1},
+		"typed": []int{id},
+		"empty": []
+	}`
+
+	data, err := GenerateCustom(template, 3, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 {
+		t.Fatalf("Expected data for 3 nodes, got %d", len(data))
+	}
+
+	first := data[0].(map[string]any)
+	if first["isLeader"] != true || data[1].(map[string]any)["isLeader"] != false {
+		t.Error("Only node 0 should be the leader")
+	}
+	if !reflect.DeepEqual(first["peers"], []any{2., 1.}) {
+		t.Errorf("Unexpected peers %v", first["peers"])
+	}
+	if first["name"] != "node-0" || first["second"] != 20. || first["none"] != nil {
+		t.Errorf("Unexpected data %v", first)
+	}
+	if !reflect.DeepEqual(first["role"], map[string]any{"leader": true}) || data[1].(map[string]any)["role"] != nil {
+		t.Errorf("Unexpected roles %v", data)
+	}
+	if !reflect.DeepEqual(data[1].(map[string]any)["point"], map[string]any{"X": 1., "Y": -1.}) {
+		t.Errorf("Unexpected point %v", data[1])
+	}
+	if !reflect.DeepEqual(first["counts"], map[string]any{"x": 1.}) || !reflect.DeepEqual(data[2].(map[string]any)["typed"], []any{2.}) {
+		t.Errorf("Unexpected typed literals %v", data[2])
+	}
+	if !reflect.DeepEqual(first["empty"], []any{}) {
+		t.Errorf("Unexpected empty array %v", first["empty"])
+	}
+	if v := first["value"].(float64); v < 0 || v > 100 {
+		t.Errorf("Random value %v out of range", v)
+	}
+
+	again, _ := GenerateCustom(template, 3, 42)
+	if !reflect.DeepEqual(data, again) {
+		t.Error("The same seed should generate the same data")
+	}
+}
+
+func TestGenerateCustom_Invalid(t *testing.T) {
+	for _, template := range []string{`{"value": }`, `{"value": unknown}`, `{"value": make(chan int)}`} {
+		if _, err := GenerateCustom(template, 2, 1); err == nil {
+			t.Errorf("Expected an error for %s", template)
+		}
+	}
+}
+
+func TestGenerateCustom_Error_Position(t *testing.T) {
+	template := "{\n\t\"value\": 1,\n\t\"peers\": [unknown]\n}"
+	_, err := GenerateCustom(template, 2, 1)
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid template : 3:11: ") || strings.Contains(err.Error(), "14:") {
+		t.Errorf("Expected the error to point into the template, got %v", err)
+	}
+}
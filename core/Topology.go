@@ -0,0 +1,81 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Topology creates the connections between n nodes for a named connection
+// scheme. Supported are
+//   - none
+//   - ring, a unidirectional ring i -> i+1
+//   - bi-ring, a bidirectional ring
+//   - line, a bidirectional line
+//   - star, bidirectional connections between node 0 and all others
+//   - tree, a bidirectional binary tree rooted at node 0
+//   - complete, every node is connected to every other node
+//   - random:p, every directed connection exists with probability p
+func Topology(name string, n int, rng *rand.Rand) (bus.Connections, error) {
+	var res bus.Connections
+	both := func(a, b int) {
+		res = append(res, bus.Connection{From: a, To: b}, bus.Connection{From: b, To: a})
+	}
+
+	scheme, param, _ := strings.Cut(name, ":")
+	switch scheme {
+	case "none":
+	case "ring":
+		for i := 0; i < n && n > 1; i++ {
+			res = append(res, bus.Connection{From: i, To: (i + 1) % n})
+		}
+	case "bi-ring":
+		for i := 0; i < n && n > 2; i++ {
+			both(i, (i+1)%n)
+		}
+		if n == 2 {
+			both(0, 1)
+		}
+	case "line":
+		for i := 0; i+1 < n; i++ {
+			both(i, i+1)
+		}
+	case "star":
+		for i := 1; i < n; i++ {
+			both(0, i)
+		}
+	case "tree":
+		for i := 1; i < n; i++ {
+			both((i-1)/2, i)
+		}
+	case "complete":
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if i != j {
+					res = append(res, bus.Connection{From: i, To: j})
+				}
+			}
+		}
+	case "random":
+		p, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid probability for random topology : %w", err)
+		}
+		if p < 0 || p > 1 {
+			return nil, fmt.Errorf("the probability of a random topology must be within [0, 1], got %v", p)
+		}
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if i != j && rng.Float64() < p {
+					res = append(res, bus.Connection{From: i, To: j})
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown topology %q", name)
+	}
+
+	return res, nil
+}
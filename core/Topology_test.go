@@ -0,0 +1,56 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTopology(t *testing.T) {
+	c := func(from, to int) bus.Connection {
+		return bus.Connection{From: from, To: to}
+	}
+
+	tests := []struct {
+		name string
+		n    int
+		want bus.Connections
+		err  bool
+	}{
+		{"none", 3, nil, false},
+		{"ring", 3, bus.Connections{c(0, 1), c(1, 2), c(2, 0)}, false},
+		{"ring", 1, nil, false},
+		{"bi-ring", 3, bus.Connections{c(0, 1), c(1, 0), c(1, 2), c(2, 1), c(2, 0), c(0, 2)}, false},
+		{"bi-ring", 2, bus.Connections{c(0, 1), c(1, 0)}, false},
+		{"tree", 4, bus.Connections{c(0, 1), c(1, 0), c(0, 2), c(2, 0), c(1, 3), c(3, 1)}, false},
+		{"random:0", 4, nil, false},
+		{"random:1", 3, bus.Connections{c(0, 1), c(0, 2), c(1, 0), c(1, 2), c(2, 0), c(2, 1)}, false},
+		{"random:abc", 3, nil, true},
+		{"random:1.5", 3, nil, true},
+		{"random", 3, nil, true},
+		{"hypercube", 3, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Topology(tt.name, tt.n, rand.New(rand.NewSource(1)))
+		if (err != nil) != tt.err {
+			t.Errorf("%s with %d nodes : unexpected error %v", tt.name, tt.n, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s with %d nodes : expected %v, got %v", tt.name, tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestTopology_Random_Seed(t *testing.T) {
+	a, _ := Topology("random:0.5", 6, rand.New(rand.NewSource(7)))
+	b, _ := Topology("random:0.5", 6, rand.New(rand.NewSource(7)))
+	if !reflect.DeepEqual(a, b) {
+		t.Error("The same seed should create the same topology")
+	}
+	if len(a) == 0 || len(a) == 30 {
+		t.Errorf("Expected some but not all of the 30 connections, got %d", len(a))
+	}
+}
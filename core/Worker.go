@@ -0,0 +1,206 @@
+package core
+
+import (
+	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/nodeproto"
+	"errors"
+	"net"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// RunWorker is the entry point of a child process running the code of a single
+// node, see processExec. fSend and fAwait are forwarded to the emulator.
+func RunWorker(addr string, id int) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	c := nodeproto.NewConn(conn, conn)
+	if err := c.Write(nodeproto.Frame{Type: nodeproto.Hello, Id: id}); err != nil {
+		return err
+	}
+
+	init, err := c.Read()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out, in := nonNil(init.OutNeighbors), nonNil(init.InNeighbors)
+	w := &worker{
+		c:         c,
+		pending:   make(map[int]chan nodeproto.Frame),
+		neighbors: newNeighbors(ctx, out, in),
+	}
+	go w.dispatch(cancel)
+
+	ctx = context.WithValue(ctx, "custom", init.Custom)
+	ctx = context.WithValue(ctx, "out-neighbors", out)
+	ctx = context.WithValue(ctx, "in-neighbors", in)
+	ctx = context.WithValue(ctx, "id", init.Id)
+	ctx = context.WithValue(ctx, "neighbors", w.neighbors.get)
+	ctx = context.WithValue(ctx, "on-topology-change", w.neighbors.onChange)
+	fSend, fAwait := w.sender(ctx), w.awaiter(ctx)
+	ctx = context.WithValue(ctx, "send", fSend)
+	ctx = context.WithValue(ctx, "await", fAwait)
+	ctx = context.WithValue(ctx, "call", w.caller(ctx))
+	ctx = context.WithValue(ctx, "handle", w.handle)
+
+	userRes, output, err := interpretExec(ctx, compileProgram(Code(init.Code)), fSend, fAwait)
+
+	res := nodeproto.Frame{Type: nodeproto.Result, Data: userRes}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if output != "" {
+		c.Write(nodeproto.Frame{Type: nodeproto.Log, Text: output})
+	}
+	if err := c.Write(res); err != nil {
+		return err
+	}
+
+	// keep serving calls until stopped
+	<-ctx.Done()
+	return nil
+}
+
+func nonNil(ids []int) []int {
+	if ids == nil {
+		return []int{}
+	}
+	return ids
+}
+
+// the emulators side of a connection as seen from a worker
+type worker struct {
+	c         *nodeproto.Conn
+	neighbors *neighbors
+
+	mu      sync.Mutex
+	seq     int
+	pending map[int]chan nodeproto.Frame
+	handler handlerFunc
+}
+
+// routes replies to the pending requests, cancels the code on stop or once the
+// connection to the emulator is lost
+func (w *worker) dispatch(cancel context.CancelFunc) {
+	defer cancel()
+	for {
+		f, err := w.c.Read()
+		if err != nil {
+			return
+		}
+
+		if f.Type == nodeproto.Stop {
+			cancel()
+			continue
+		}
+
+		if f.Type == nodeproto.Topology {
+			w.neighbors.set(nonNil(f.OutNeighbors), nonNil(f.InNeighbors))
+			continue
+		}
+
+		if f.Type == nodeproto.Request {
+			w.mu.Lock()
+			handler := w.handler
+			w.mu.Unlock()
+			go w.answer(handler, f)
+			continue
+		}
+
+		w.mu.Lock()
+		reply, ok := w.pending[f.Seq]
+		delete(w.pending, f.Seq)
+		w.mu.Unlock()
+
+		if ok {
+			reply <- f
+		}
+	}
+}
+
+// sends a request and waits for the corresponding reply
+func (w *worker) request(ctx context.Context, f nodeproto.Frame) (nodeproto.Frame, bool) {
+	reply := make(chan nodeproto.Frame, 1)
+	w.mu.Lock()
+	w.seq++
+	f.Seq = w.seq
+	w.pending[f.Seq] = reply
+	w.mu.Unlock()
+
+	if err := w.c.Write(f); err != nil {
+		return f, false
+	}
+
+	select {
+	case <-ctx.Done():
+		return f, false
+	case res := <-reply:
+		return res, true
+	}
+}
+
+func (w *worker) sender(ctx context.Context) sendFunc {
+	return func(targetId int, data any) int {
+		res, ok := w.request(ctx, nodeproto.Frame{Type: nodeproto.Send, To: targetId, Data: data})
+		if !ok {
+			return 0
+		}
+		return res.Cnt
+	}
+}
+
+func (w *worker) caller(ctx context.Context) callFunc {
+	return func(targetId int, request any) (any, error) {
+		res, ok := w.request(ctx, nodeproto.Frame{Type: nodeproto.Call, To: targetId, Data: request})
+		if !ok {
+			return nil, ctx.Err()
+		}
+		if res.Error != "" {
+			return res.Data, errors.New(res.Error)
+		}
+		return res.Data, nil
+	}
+}
+
+func (w *worker) handle(handler handlerFunc) {
+	w.mu.Lock()
+	w.handler = handler
+	w.mu.Unlock()
+
+	w.c.Write(nodeproto.Frame{Type: nodeproto.Handle})
+}
+
+// answers a call forwarded by the emulator, the handler is registered before
+// the emulator forwards any call
+func (w *worker) answer(handler handlerFunc, f nodeproto.Frame) {
+	res := nodeproto.Frame{Type: nodeproto.Response, Seq: f.Seq}
+	data, err := callHandler(handler, f.Id, f.Data)
+	res.Data = data
+	if err != nil {
+		res.Error = err.Error()
+	}
+	w.c.Write(res)
+}
+
+func (w *worker) awaiter(ctx context.Context) awaitFunc {
+	return func(cnt int) []any {
+		res, ok := w.request(ctx, nodeproto.Frame{Type: nodeproto.Await, Cnt: cnt})
+		if !ok {
+			return []any{}
+		}
+
+		received := make([]any, len(res.Messages))
+		for i, m := range res.Messages {
+			received[i] = bus.SendTask{From: m.From, To: m.To, Data: m.Data}
+		}
+		return received
+	}
+}
@@ -0,0 +1,115 @@
+package fynegui
+
+import (
+	"distributed-sys-emulator/bus"
+	"image/color"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Declare conformance with the Component interface
+var _ Component = (*ChurnEditor)(nil)
+
+// configures how nodes join and leave the network while it runs
+type ChurnEditor struct {
+	*fyne.Container
+}
+
+func NewChurnEditor(eb bus.EventBus) *ChurnEditor {
+	status := canvas.NewText("", statusOk)
+	setStatus := func(text string, c color.Color) {
+		status.Text = text
+		status.Color = c
+		status.Refresh()
+	}
+
+	kinds := []string{string(bus.NoChurn), string(bus.PoissonChurn), string(bus.TraceChurn)}
+	kindSelect := widget.NewSelect(kinds, nil)
+	kindSelect.SetSelected(string(bus.NoChurn))
+
+	// poisson
+	rateEntry := widget.NewEntry()
+	rateEntry.PlaceHolder = "joins per second"
+	sessions := []string{
+		string(bus.ExponentialSession),
+		string(bus.ParetoSession),
+		string(bus.WeibullSession),
+		string(bus.FixedSession),
+	}
+	sessionSelect := widget.NewSelect(sessions, nil)
+	sessionSelect.SetSelected(string(bus.ExponentialSession))
+	meanEntry := widget.NewEntry()
+	meanEntry.PlaceHolder = "mean session in seconds"
+	shapeEntry := widget.NewEntry()
+	shapeEntry.PlaceHolder = "shape (pareto, weibull)"
+
+	// trace
+	traceEntry := widget.NewEntry()
+	traceEntry.PlaceHolder = "path of the trace file"
+
+	bootstrapEntry := widget.NewEntry()
+	bootstrapEntry.PlaceHolder = "random:2, node:0, all or none"
+	seedEntry := widget.NewEntry()
+	seedEntry.PlaceHolder = "seed"
+	seedEntry.OnChanged = func(s string) {
+		seedEntry.Text = extractWholeNumbers(s)
+	}
+
+	apply := widget.NewButton("Apply", func() {
+		rate, _ := strconv.ParseFloat(rateEntry.Text, 64)
+		mean, _ := strconv.ParseFloat(meanEntry.Text, 64)
+		shape, _ := strconv.ParseFloat(shapeEntry.Text, 64)
+		seed, _ := strconv.ParseInt(seedEntry.Text, 10, 64)
+
+		config := bus.Churn{
+			Kind:        bus.ChurnKind(kindSelect.Selected),
+			ArrivalRate: rate,
+			Session: bus.Session{
+				Kind:  bus.SessionKind(sessionSelect.Selected),
+				Mean:  time.Duration(mean * float64(time.Second)),
+				Shape: shape,
+			},
+			Trace:     traceEntry.Text,
+			Bootstrap: bootstrapEntry.Text,
+			Seed:      seed,
+		}
+		eb.Publish(bus.Event{Type: bus.ChurnConfigChangeEvt, Data: config})
+	})
+
+	eb.Bind(bus.ChurnConfigResultEvt, func(res bus.ChurnConfigResult) {
+		if res.Err != "" {
+			setStatus(res.Err, statusErr)
+			return
+		}
+		setStatus("applied, takes effect with the next run", statusOk)
+	}, latest())
+
+	form := widget.NewForm(
+		widget.NewFormItem("churn", kindSelect),
+		widget.NewFormItem("arrivals", rateEntry),
+		widget.NewFormItem("sessions", sessionSelect),
+		widget.NewFormItem("mean", meanEntry),
+		widget.NewFormItem("shape", shapeEntry),
+		widget.NewFormItem("trace", traceEntry),
+		widget.NewFormItem("bootstrap", bootstrapEntry),
+		widget.NewFormItem("seed", seedEntry),
+	)
+
+	content := container.NewVBox(
+		widget.NewLabel("Nodes joining and leaving while the network runs :"),
+		form,
+		apply,
+		status,
+	)
+
+	return &ChurnEditor{content}
+}
+
+func (c ChurnEditor) GetCanvasObj() fyne.CanvasObject {
+	return c.Container
+}
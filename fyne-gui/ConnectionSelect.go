@@ -2,6 +2,7 @@ package fynegui
 
 import (
 	"distributed-sys-emulator/bus"
+	"fmt"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -15,12 +16,48 @@ type ConnectionsSelect struct {
 	*fyne.Container
 }
 
+var reliabilityKinds = []string{string(bus.Unreliable), string(bus.AtLeastOnce), string(bus.ExactlyOnce)}
+
 func NewConnectionsSelect(eb bus.EventBus) *ConnectionsSelect {
 	// keep node count up to date
 	var nodeCnt int
 	var connections bus.Connections
 	connectionsWrap := container.NewHBox()
 
+	// delivery guarantee of a single connection, overriding the control bars
+	var selected bus.Connection
+	reliabilitySelect := widget.NewSelect(reliabilityKinds, func(s string) {
+		// cleared when another connection is selected
+		if s == "" {
+			return
+		}
+		config := bus.Reliability{Kind: bus.ReliabilityKind(s), Connections: bus.Connections{selected}}
+		e := bus.Event{Type: bus.ReliabilityChangeEvt, Data: config}
+		eb.Publish(e)
+	})
+	reliabilitySelect.PlaceHolder = "reliability"
+	reliabilitySelect.Disable()
+
+	connectionSelect := widget.NewSelect(nil, func(s string) {
+		if s == "" {
+			return
+		}
+		fmt.Sscanf(s, "%d -> %d", &selected.From, &selected.To)
+		reliabilitySelect.ClearSelected()
+		reliabilitySelect.Enable()
+	})
+	connectionSelect.PlaceHolder = "connection"
+
+	refreshConnectionSelect := func() {
+		options := make([]string, len(connections))
+		for i, c := range connections {
+			options[i] = fmt.Sprintf("%d -> %d", c.From, c.To)
+		}
+		connectionSelect.Options = options
+		connectionSelect.ClearSelected()
+		reliabilitySelect.Disable()
+	}
+
 	addCheckboxes := func() {
 		connectionsWrap.RemoveAll()
 
@@ -74,9 +111,16 @@ func NewConnectionsSelect(eb bus.EventBus) *ConnectionsSelect {
 		// refresh
 		addCheckboxes()
 		connectionsWrap.Refresh()
-	})
+		refreshConnectionSelect()
+	}, latest())
+
+	eb.Bind(bus.NetworkConnectionsEvt, func(newConnections bus.Connections) {
+		connections = newConnections
+		refreshConnectionSelect()
+	}, latest())
 
-	return &ConnectionsSelect{connectionsWrap}
+	reliability := container.NewHBox(connectionSelect, reliabilitySelect)
+	return &ConnectionsSelect{container.NewVBox(connectionsWrap, reliability)}
 }
 
 func (c ConnectionsSelect) GetCanvasObj() fyne.CanvasObject {
@@ -2,9 +2,13 @@ package fynegui
 
 import (
 	"distributed-sys-emulator/bus"
+	"encoding/json"
 	"fmt"
 	"image/color"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -22,12 +26,21 @@ type Console struct {
 func NewConsole(eb bus.EventBus) *Console {
 	c := container.NewBorder(nil, nil, nil, nil)
 
+	// the outputs are stored by the publisher, the console is redrawn from a
+	// queue of its own
+	var mu sync.Mutex
 	var nodeCnt int
 	var outputs []string
 	var results []any
 
 	// depending on the outputs, create console boxes
 	refresh := func() {
+		mu.Lock()
+		nodeCnt := nodeCnt
+		outputs := append([]string(nil), outputs...)
+		results := append([]any(nil), results...)
+		mu.Unlock()
+
 		c.RemoveAll()
 
 		headerRow := container.NewGridWithColumns(nodeCnt)
@@ -75,6 +88,7 @@ func NewConsole(eb bus.EventBus) *Console {
 
 	// update node count and output slice size as required
 	eb.Bind(bus.NetworkResizeEvt, func(resizeData bus.NetworkResize) {
+		mu.Lock()
 		nodeCnt = resizeData.Cnt
 		if nodeCnt > len(outputs) {
 			for i := 0; i <= nodeCnt-len(outputs); i++ {
@@ -85,20 +99,159 @@ func NewConsole(eb bus.EventBus) *Console {
 			outputs = outputs[:nodeCnt]
 			results = results[:nodeCnt]
 		}
+		mu.Unlock()
 		refresh()
-	})
+	}, latest())
 
-	// update outputs
+	// update outputs, every output is kept while redraws skip the outputs that
+	// arrived in the meantime
 	eb.Bind(bus.NodeOutputEvt, func(out bus.NodeOutput) {
-		outputs[out.NodeId] = out.Log
-		results[out.NodeId] = out.Result
-		refresh()
+		mu.Lock()
+		defer mu.Unlock()
+		if int(out.NodeId) < len(outputs) {
+			outputs[out.NodeId] = out.Log
+			results[out.NodeId] = out.Result
+		}
 	})
+	eb.Bind(bus.NodeOutputEvt, func(bus.NodeOutput) {
+		refresh()
+	}, latest())
 
-	console := &Console{c}
+	// compile errors are shown once instead of in every nodes output
+	compileText := canvas.NewText("", color.RGBA{204, 51, 51, 255})
+	eb.Bind(bus.CodeCompileResultEvt, func(res bus.CodeCompileResult) {
+		compileText.Text = ""
+		if res.Err != "" {
+			compileText.Text = "compile error : " + res.Err
+		}
+		compileText.Refresh()
+	}, latest())
+
+	checksBar, verdictText := newChecks(eb)
+	summary := container.NewVBox(compileText, verdictText, newMetricsSummary(eb))
+	wrapper := container.NewBorder(checksBar, summary, nil, nil, c)
+
+	console := &Console{wrapper}
 	return console
 }
 
+// creates the inputs to configure which checks are run over the node results
+// and a summary line showing the verdict of the most recent run
+func newChecks(eb bus.EventBus) (*fyne.Container, *canvas.Text) {
+	config := bus.CheckConfig{}
+	publish := func() {
+		e := bus.Event{Type: bus.CheckConfigChangeEvt, Data: config}
+		eb.Publish(e)
+	}
+
+	agreement := widget.NewCheck("Agreement", func(b bool) {
+		config.Agreement = b
+		publish()
+	})
+
+	validity := widget.NewCheck("Validity", func(b bool) {
+		config.Validity = b
+		publish()
+	})
+	validityKey := widget.NewEntry()
+	validityKey.PlaceHolder = "custom data key"
+	validityKey.OnChanged = func(s string) {
+		config.ValidityKey = s
+		publish()
+	}
+
+	uniqueness := widget.NewCheck("Uniqueness", func(b bool) {
+		config.Uniqueness = b
+		publish()
+	})
+	marker := widget.NewEntry()
+	marker.PlaceHolder = "marker e.g. true"
+	marker.OnChanged = func(s string) {
+		// anything that isn't valid json is compared as a string
+		var data any
+		if err := json.Unmarshal([]byte(s), &data); err != nil {
+			data = s
+		}
+		config.UniquenessMarker = data
+		publish()
+	}
+
+	bar := container.NewHBox(
+		agreement,
+		widget.NewSeparator(),
+		validity,
+		validityKey,
+		widget.NewSeparator(),
+		uniqueness,
+		marker,
+	)
+
+	verdictText := canvas.NewText("", color.Black)
+	verdictText.TextStyle = fyne.TextStyle{Bold: true}
+
+	eb.Bind(bus.RunVerdictEvt, func(verdict bus.Verdict) {
+		if len(verdict.Checks) == 0 {
+			verdictText.Text = ""
+			verdictText.Refresh()
+			return
+		}
+
+		summary := []string{"FAIL"}
+		verdictText.Color = color.RGBA{204, 51, 51, 255}
+		if verdict.Passed {
+			summary[0] = "PASS"
+			verdictText.Color = color.RGBA{51, 153, 51, 255}
+		}
+
+		for _, c := range verdict.Checks {
+			if c.Passed {
+				summary = append(summary, c.Name+" ✓")
+			} else {
+				summary = append(summary, c.Name+" ✗ ("+c.Reason+")")
+			}
+		}
+
+		verdictText.Text = strings.Join(summary, "   ")
+		verdictText.Refresh()
+	}, latest())
+
+	return bar, verdictText
+}
+
 func (e *Console) GetCanvasObj() fyne.CanvasObject {
 	return e.Container
 }
+
+// creates a line summarizing the cost of the most recent run
+func newMetricsSummary(eb bus.EventBus) *widget.Label {
+	label := widget.NewLabel("")
+	label.Hide()
+
+	eb.Bind(bus.MetricsEvt, func(m bus.Metrics) {
+		if !m.Final {
+			return
+		}
+
+		var latencySum time.Duration
+		delivered := 0
+		for _, e := range m.Edges {
+			latencySum += e.Latency * time.Duration(e.Messages-e.InFlight)
+			delivered += e.Messages - e.InFlight
+		}
+		latency := time.Duration(0)
+		if delivered > 0 {
+			latency = latencySum / time.Duration(delivered)
+		}
+
+		var awaitTime time.Duration
+		for _, n := range m.Nodes {
+			awaitTime += n.AwaitTime
+		}
+
+		label.SetText(fmt.Sprintf("messages %d   bytes %d   rounds %d   avg latency %v   time awaiting %v   elapsed %v",
+			m.Messages, m.Bytes, m.Rounds, latency, awaitTime.Round(time.Millisecond), m.Elapsed.Round(time.Millisecond)))
+		label.Show()
+	}, latest())
+
+	return label
+}
@@ -32,7 +32,61 @@ func NewControlBar(eb bus.EventBus) *ControlBar {
 		nodeCnt := resizeData.Cnt
 		nodeCntEntry.Text = strconv.Itoa(nodeCnt)
 		nodeCntEntry.Refresh()
+	}, latest())
+
+	// probability in percent for messages to get lost
+	lossEntry := widget.NewEntry()
+	lossEntry.PlaceHolder = "loss %"
+	lossEntry.OnChanged = func(s string) {
+		lossEntry.Text = extractWholeNumbers(s)
+	}
+	lossEntry.OnSubmitted = func(s string) {
+		loss, _ := strconv.Atoi(s)
+		config := bus.LinkConfig{Loss: float64(loss) / 100}
+		e := bus.Event{Type: bus.LinkConfigChangeEvt, Data: config}
+		eb.Publish(e)
+	}
+
+	// the program every node runs with the external backend
+	commandEntry := widget.NewEntry()
+	commandEntry.PlaceHolder = "command"
+	commandEntry.OnSubmitted = func(s string) {
+		backend := bus.Backend{Kind: bus.ExternalBackend, Command: s}
+		e := bus.Event{Type: bus.BackendChangeEvt, Data: backend}
+		eb.Publish(e)
+	}
+
+	// where the nodes code is executed
+	backends := []string{string(bus.GoroutineBackend), string(bus.ProcessBackend), string(bus.NativeBackend), string(bus.ExternalBackend)}
+	backendSelect := widget.NewSelect(backends, func(s string) {
+		backend := bus.Backend{Kind: bus.BackendKind(s)}
+		if backend.Kind == bus.ExternalBackend {
+			backend.Command = commandEntry.Text
+			commandEntry.Enable()
+		} else {
+			commandEntry.Disable()
+		}
+		e := bus.Event{Type: bus.BackendChangeEvt, Data: backend}
+		eb.Publish(e)
+	})
+	backendSelect.SetSelected(string(bus.GoroutineBackend))
+
+	// how messages are passed between nodes
+	modes := []string{string(bus.CopyMessages), string(bus.EncodeMessages), string(bus.ShareMessages)}
+	modeSelect := widget.NewSelect(modes, func(s string) {
+		mode := bus.MessageMode{Kind: bus.MessageModeKind(s)}
+		e := bus.Event{Type: bus.MessageModeChangeEvt, Data: mode}
+		eb.Publish(e)
+	})
+	modeSelect.SetSelected(string(bus.CopyMessages))
+
+	// delivery guarantee of all connections without their own setting
+	reliabilitySelect := widget.NewSelect(reliabilityKinds, func(s string) {
+		config := bus.Reliability{Kind: bus.ReliabilityKind(s)}
+		e := bus.Event{Type: bus.ReliabilityChangeEvt, Data: config}
+		eb.Publish(e)
 	})
+	reliabilitySelect.SetSelected(string(bus.Unreliable))
 
 	var startButton, stopButton, debugButton, continueButton *widget.Button
 
@@ -87,6 +141,11 @@ func NewControlBar(eb bus.EventBus) *ControlBar {
 		continueButton,
 		widget.NewSeparator(),
 		nodeCntEntry,
+		lossEntry,
+		backendSelect,
+		commandEntry,
+		modeSelect,
+		reliabilitySelect,
 	)
 
 	return &ControlBar{execution}
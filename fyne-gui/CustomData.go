@@ -0,0 +1,102 @@
+package fynegui
+
+import (
+	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/schema"
+	"image/color"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Declare conformance with the Component interface
+var _ Component = (*CustomDataEditor)(nil)
+
+// edits the custom data of all nodes at once : a json schema the data has to
+// match and a template to generate it from
+type CustomDataEditor struct {
+	*fyne.Container
+}
+
+var statusOk = color.RGBA{R: 64, G: 176, B: 96, A: 255}
+var statusErr = color.RGBA{R: 224, G: 64, B: 64, A: 255}
+
+func NewCustomDataEditor(eb bus.EventBus) *CustomDataEditor {
+	status := canvas.NewText("", statusOk)
+	setStatus := func(text string, c color.Color) {
+		status.Text = text
+		status.Color = c
+		status.Refresh()
+	}
+
+	schemaInput := widget.NewMultiLineEntry()
+	schemaInput.PlaceHolder = `{"type": "object", "required": ["value"]}`
+	schemaInput.SetMinRowsVisible(6)
+	applySchema := widget.NewButton("Apply schema", func() {
+		var s any
+		if schemaInput.Text != "" {
+			var err error
+			s, err = schema.Parse(schemaInput.Text)
+			if err != nil {
+				setStatus(err.Error(), statusErr)
+				return
+			}
+		}
+
+		e := bus.Event{Type: bus.CustomSchemaChangeEvt, Data: bus.CustomSchema{Schema: s}}
+		eb.Publish(e)
+		if s == nil {
+			setStatus("schema removed", statusOk)
+		} else {
+			setStatus("schema applied", statusOk)
+		}
+	})
+
+	templateInput := widget.NewMultiLineEntry()
+	templateInput.PlaceHolder = `{"value": rand(0, 100), "isLeader": id == 0}`
+	templateInput.SetMinRowsVisible(6)
+
+	seedInput := widget.NewEntry()
+	seedInput.PlaceHolder = "seed"
+	seedInput.SetText("1")
+	seedInput.OnChanged = func(s string) {
+		seedInput.Text = extractWholeNumbers(s)
+	}
+
+	generate := widget.NewButton("Generate", func() {
+		seed, _ := strconv.ParseInt(seedInput.Text, 10, 64)
+		t := bus.CustomTemplate{Template: templateInput.Text, Seed: seed}
+		eb.Publish(bus.Event{Type: bus.CustomTemplateEvt, Data: t})
+	})
+
+	eb.Bind(bus.CustomTemplateResultEvt, func(res bus.CustomTemplateResult) {
+		if res.Err != "" {
+			setStatus(res.Err, statusErr)
+			return
+		}
+		setStatus("generated the custom data of all nodes", statusOk)
+	}, latest())
+
+	help := widget.NewLabel("id, n, rand(a, b), randf(), choice(...), null, fmt, math and strings\nare available within the template")
+
+	content := container.NewVBox(
+		widget.NewLabel("Json schema of the custom data :"),
+		schemaInput,
+		applySchema,
+		widget.NewSeparator(),
+		widget.NewLabel("Template for the custom data of every node :"),
+		templateInput,
+		help,
+		container.NewBorder(nil, nil, widget.NewLabel("seed"), generate, seedInput),
+		status,
+	)
+
+	return &CustomDataEditor{content}
+}
+
+func (c CustomDataEditor) GetCanvasObj() fyne.CanvasObject {
+	return c.Container
+}
@@ -66,7 +66,7 @@ func NewTextEditor(path string, _ fyne.Window, eb bus.EventBus) *Editor {
 			return
 		}
 		log.Error(err)
-	})
+	}, queued())
 
 	return &editor
 }
@@ -0,0 +1,104 @@
+package fynegui
+
+import (
+	"distributed-sys-emulator/bus"
+	"image/color"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Declare conformance with the Component interface
+var _ Component = (*GeoEditor)(nil)
+
+// configures where nodes are located and how that affects their links
+type GeoEditor struct {
+	*fyne.Container
+}
+
+func NewGeoEditor(eb bus.EventBus) *GeoEditor {
+	status := canvas.NewText("", statusOk)
+	setStatus := func(text string, c color.Color) {
+		status.Text = text
+		status.Color = c
+		status.Refresh()
+	}
+
+	placements := []string{
+		string(bus.CirclePlacement),
+		string(bus.RandomPlacement),
+		string(bus.CustomPlacement),
+		string(bus.ManualPlacement),
+	}
+	placementSelect := widget.NewSelect(placements, nil)
+	placementSelect.SetSelected(string(bus.CirclePlacement))
+
+	sizeEntry := widget.NewEntry()
+	sizeEntry.PlaceHolder = "side length of the area (100)"
+	latencyEntry := widget.NewEntry()
+	latencyEntry.PlaceHolder = "ms per link"
+	perUnitEntry := widget.NewEntry()
+	perUnitEntry.PlaceHolder = "ms per unit of distance"
+	rangeEntry := widget.NewEntry()
+	rangeEntry.PlaceHolder = "radio range, empty to connect by hand"
+	seedEntry := widget.NewEntry()
+	seedEntry.PlaceHolder = "seed"
+	seedEntry.OnChanged = func(s string) {
+		seedEntry.Text = extractWholeNumbers(s)
+	}
+
+	ms := func(s string) time.Duration {
+		f, _ := strconv.ParseFloat(s, 64)
+		return time.Duration(f * float64(time.Millisecond))
+	}
+
+	apply := widget.NewButton("Apply", func() {
+		size, _ := strconv.ParseFloat(sizeEntry.Text, 64)
+		radioRange, _ := strconv.ParseFloat(rangeEntry.Text, 64)
+		seed, _ := strconv.ParseInt(seedEntry.Text, 10, 64)
+
+		config := bus.Geo{
+			Placement: bus.Placement(placementSelect.Selected),
+			Size:      size,
+			Latency:   ms(latencyEntry.Text),
+			PerUnit:   ms(perUnitEntry.Text),
+			Range:     radioRange,
+			Seed:      seed,
+		}
+		eb.Publish(bus.Event{Type: bus.GeoConfigChangeEvt, Data: config})
+	})
+
+	eb.Bind(bus.GeoConfigResultEvt, func(res bus.GeoConfigResult) {
+		if res.Err != "" {
+			setStatus(res.Err, statusErr)
+			return
+		}
+		setStatus("applied", statusOk)
+	}, latest())
+
+	form := widget.NewForm(
+		widget.NewFormItem("placement", placementSelect),
+		widget.NewFormItem("size", sizeEntry),
+		widget.NewFormItem("latency", latencyEntry),
+		widget.NewFormItem("per unit", perUnitEntry),
+		widget.NewFormItem("range", rangeEntry),
+		widget.NewFormItem("seed", seedEntry),
+	)
+
+	content := container.NewVBox(
+		widget.NewLabel("Node positions, link latencies and radio range :"),
+		form,
+		apply,
+		status,
+	)
+
+	return &GeoEditor{content}
+}
+
+func (g GeoEditor) GetCanvasObj() fyne.CanvasObject {
+	return g.Container
+}
@@ -4,12 +4,14 @@ import (
 	"distributed-sys-emulator/bus"
 	"distributed-sys-emulator/log"
 	"embed"
+	"errors"
 	"path"
 	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
@@ -23,6 +25,22 @@ type Component interface {
 
 var InitialWindowSize = fyne.NewSize(1000, 800)
 
+// events buffered per gui callback before the delivery waits for it
+const guiQueueSize = 64
+
+// the gui redraws from its callbacks, each gets a queue so a redraw does not
+// hold up the other subscribers of the event. Callbacks that apply every event
+// in turn, like the animations of the debug mode, wait once their queue is full.
+func queued() bus.BindOption {
+	return bus.Queue(guiQueueSize, bus.Block)
+}
+
+// callbacks that only show the latest state skip the states they could not
+// keep up with instead, so they never hold up the delivery
+func latest() bus.BindOption {
+	return bus.Queue(1, bus.CoalesceLatest)
+}
+
 // embed code examples
 //
 //go:embed resources/*.go
@@ -57,6 +75,8 @@ func RunGUI(eb bus.EventBus) {
 
 	console := NewConsole(eb)
 
+	metrics := NewMetricsPanel(eb)
+
 	//-------------------------------------------------------
 	// EMBED COMPONENTS IN LAYOUT
 
@@ -69,6 +89,54 @@ func RunGUI(eb bus.EventBus) {
 	})
 	execution.Add(connect)
 
+	// custom data of all nodes
+	customData := NewCustomDataEditor(eb)
+	customDataModal := NewModal(customData.GetCanvasObj(), wcanvas)
+	customDataBtn := widget.NewButton("Custom Data", func() {
+		customDataModal.Resize(fyne.NewSize(500, 500))
+		customDataModal.Show()
+	})
+	execution.Add(customDataBtn)
+
+	// nodes joining and leaving while running
+	churn := NewChurnEditor(eb)
+	churnModal := NewModal(churn.GetCanvasObj(), wcanvas)
+	churnBtn := widget.NewButton("Churn", func() {
+		churnModal.Resize(fyne.NewSize(400, 450))
+		churnModal.Show()
+	})
+	execution.Add(churnBtn)
+
+	// node positions and link latencies
+	geo := NewGeoEditor(eb)
+	geoModal := NewModal(geo.GetCanvasObj(), wcanvas)
+	geoBtn := widget.NewButton("Geography", func() {
+		geoModal.Resize(fyne.NewSize(400, 400))
+		geoModal.Show()
+	})
+	execution.Add(geoBtn)
+
+	// generate a module to run the nodes outside of the emulator
+	export := widget.NewButton("Export", func() {
+		dialog.ShowFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err != nil || dir == nil {
+				return
+			}
+			target := bus.ExportTarget{Dir: dir.Path()}
+			e := bus.Event{Type: bus.CodeExportEvt, Data: target}
+			eb.Publish(e)
+		}, window)
+	})
+	execution.Add(export)
+
+	eb.Bind(bus.CodeExportResultEvt, func(res bus.ExportResult) {
+		if res.Err != "" {
+			dialog.ShowError(errors.New(res.Err), window)
+			return
+		}
+		dialog.ShowInformation("Export", "Generated nodes in "+res.Dir, window)
+	}, queued())
+
 	// system file explorer
 	saveIcon := theme.DocumentSaveIcon()
 	basePath := "./"
@@ -127,9 +195,10 @@ func RunGUI(eb bus.EventBus) {
 	})
 
 	// Layout : resizable middle split with the editor left, the output console
-	// below it and everything else on the right
+	// and metrics below it and everything else on the right
 	view := container.NewBorder(execution.GetCanvasObj(), nil, nil, nil, canvasRaster)
-	devenv := container.NewBorder(editorTop, console.GetCanvasObj(), nil, nil, editor.GetCanvasObj())
+	output := container.NewHSplit(console.GetCanvasObj(), metrics.GetCanvasObj())
+	devenv := container.NewBorder(editorTop, output, nil, nil, editor.GetCanvasObj())
 	split := container.NewHSplit(devenv, view)
 
 	window.SetContent(split)
@@ -0,0 +1,198 @@
+package fynegui
+
+import (
+	"distributed-sys-emulator/bus"
+	"fmt"
+	"image/color"
+	"strconv"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Declare conformance with the Component interface
+var _ Component = (*MetricsPanel)(nil)
+
+// number of samples kept for the live charts
+const chartSamples = 60
+
+type MetricsPanel struct {
+	*fyne.Container
+}
+
+func NewMetricsPanel(eb bus.EventBus) *MetricsPanel {
+	var mu sync.Mutex
+	var prev bus.Metrics
+
+	rateChart := newChart(color.RGBA{51, 153, 153, 255})
+	rateLabel := widget.NewLabel("messages/s 0")
+	inFlightChart := newChart(color.RGBA{204, 102, 51, 255})
+	inFlightLabel := widget.NewLabel("in flight 0")
+	counters := container.NewGridWithColumns(5)
+
+	charts := container.NewGridWithColumns(2,
+		container.NewBorder(rateLabel, nil, nil, nil, rateChart.Container),
+		container.NewBorder(inFlightLabel, nil, nil, nil, inFlightChart.Container),
+	)
+
+	// refresh the charts and counters for the latest snapshot, those published
+	// while redrawing are coalesced
+	eb.Bind(bus.MetricsEvt, func(m bus.Metrics) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// a new run started
+		if m.Elapsed < prev.Elapsed || prev.Final {
+			prev = bus.Metrics{}
+			rateChart.clear()
+			inFlightChart.clear()
+		}
+
+		rate := 0.
+		if dt := (m.Elapsed - prev.Elapsed).Seconds(); dt > 0 {
+			rate = float64(m.Messages-prev.Messages) / dt
+		}
+		inFlight := 0
+		for _, e := range m.Edges {
+			inFlight += e.InFlight
+		}
+		prev = m
+
+		rateChart.add(rate)
+		inFlightChart.add(float64(inFlight))
+		rateLabel.SetText(fmt.Sprintf("messages/s %.1f", rate))
+		inFlightLabel.SetText("in flight " + strconv.Itoa(inFlight))
+
+		refreshCounters(counters, m)
+	}, latest())
+
+	panel := container.NewBorder(charts, nil, nil, nil, container.NewVScroll(counters))
+	return &MetricsPanel{panel}
+}
+
+// per node send/receive counters
+func refreshCounters(counters *fyne.Container, m bus.Metrics) {
+	maxSent := 1
+	for _, n := range m.Nodes {
+		if n.Sent > maxSent {
+			maxSent = n.Sent
+		}
+	}
+
+	counters.RemoveAll()
+	for _, header := range []string{"Node", "Sent", "Received", "Bytes out/in", "Awaiting"} {
+		label := widget.NewLabel(header)
+		label.TextStyle = fyne.TextStyle{Bold: true}
+		counters.Add(label)
+	}
+
+	for _, n := range m.Nodes {
+		sent := widget.NewProgressBar()
+		sent.Max = float64(maxSent)
+		sent.SetValue(float64(n.Sent))
+		sent.TextFormatter = func(cnt int) func() string {
+			return func() string { return strconv.Itoa(cnt) }
+		}(n.Sent)
+
+		counters.Add(widget.NewLabel("Node " + strconv.Itoa(n.NodeId)))
+		counters.Add(sent)
+		counters.Add(widget.NewLabel(strconv.Itoa(n.Received)))
+		counters.Add(widget.NewLabel(strconv.Itoa(n.BytesSent) + "/" + strconv.Itoa(n.BytesReceived)))
+		counters.Add(widget.NewLabel(n.AwaitTime.Round(time.Millisecond).String()))
+	}
+	counters.Refresh()
+}
+
+// a simple line chart over the most recent samples
+type chart struct {
+	*fyne.Container
+	layout *chartLayout
+}
+
+func newChart(c color.Color) *chart {
+	bg := canvas.NewRectangle(color.Transparent)
+	bg.StrokeColor = color.Gray{Y: 160}
+	bg.StrokeWidth = 1
+
+	lines := make([]fyne.CanvasObject, chartSamples-1)
+	for i := range lines {
+		line := canvas.NewLine(c)
+		line.StrokeWidth = 2
+		line.Hide()
+		lines[i] = line
+	}
+
+	layout := &chartLayout{}
+	objects := append([]fyne.CanvasObject{bg}, lines...)
+	return &chart{container.New(layout, objects...), layout}
+}
+
+func (c *chart) add(v float64) {
+	c.layout.mu.Lock()
+	c.layout.values = append(c.layout.values, v)
+	if len(c.layout.values) > chartSamples {
+		c.layout.values = c.layout.values[1:]
+	}
+	c.layout.mu.Unlock()
+
+	c.Container.Refresh()
+	c.layout.Layout(c.Objects, c.Size())
+}
+
+func (c *chart) clear() {
+	c.layout.mu.Lock()
+	c.layout.values = nil
+	c.layout.mu.Unlock()
+}
+
+// positions the background and the line segments of a chart
+type chartLayout struct {
+	mu     sync.Mutex
+	values []float64
+}
+
+func (l *chartLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	objects[0].Resize(size)
+	objects[0].Move(fyne.NewPos(0, 0))
+
+	max := 1.
+	for _, v := range l.values {
+		if v > max {
+			max = v
+		}
+	}
+
+	point := func(i int) fyne.Position {
+		x := size.Width * float32(i) / float32(chartSamples-1)
+		y := size.Height - size.Height*float32(l.values[i]/max)
+		return fyne.NewPos(x, y)
+	}
+
+	for i, obj := range objects[1:] {
+		line := obj.(*canvas.Line)
+		if i+1 >= len(l.values) {
+			line.Hide()
+			continue
+		}
+
+		line.Position1 = point(i)
+		line.Position2 = point(i + 1)
+		line.Show()
+		line.Refresh()
+	}
+}
+
+func (l *chartLayout) MinSize(_ []fyne.CanvasObject) fyne.Size {
+	return fyne.NewSize(150, 80)
+}
+
+func (p *MetricsPanel) GetCanvasObj() fyne.CanvasObject {
+	return p.Container
+}
@@ -2,9 +2,13 @@ package fynegui
 
 import (
 	"distributed-sys-emulator/bus"
+	"distributed-sys-emulator/schema"
 	"encoding/json"
+	"image/color"
 	"math"
+	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 
 	"fyne.io/fyne/v2"
@@ -19,13 +23,33 @@ type NetworkDiagram struct {
 	stateMu sync.Mutex
 
 	// state data
-	buttons []*widget.Button
-	nodes   []node
-	edges   []edge
+	buttons        []*widget.Button
+	dataInputs     []*widget.Entry // custom data of every node
+	positionInputs []*widget.Entry // manual placement of every node
+	byzantines     []bus.Byzantine // behaviour of every node
+	schema         any             // the custom data has to match, nil for none
+	nodes          []node
+	edges          []edge
+	traffic        map[bus.Connection]int // messages per edge in the current run
+	maxTraffic     int
+	calls          map[int]*callLinks // by call id, shown in debug mode
+	offline        map[int]bool       // nodes that left the network
+	positions      bus.NodePositions  // nodes without a position are drawn on a circle
 }
 
+// the arrows of a request and its response, drawn next to the edges
+type callLinks struct {
+	links    []*diagramwidget.BaseDiagramLink
+	returned bool
+}
+
+var callColor = color.RGBA{R: 64, G: 128, B: 224, A: 255}
+var returnColor = color.RGBA{R: 64, G: 176, B: 96, A: 255}
+
 var stopIcon = widget.NewIcon(theme.MediaStopIcon())
 var playIcon = widget.NewIcon(theme.MediaPlayIcon())
+var offlineIcon = widget.NewIcon(theme.CancelIcon())
+var byzantineIcon = widget.NewIcon(theme.WarningIcon())
 
 type node struct {
 	diagramwidget.DiagramNode
@@ -52,45 +76,82 @@ func NewNetworkDiagram(eb bus.EventBus, wcanvas fyne.Canvas) *NetworkDiagram {
 
 	eb.Bind(bus.SentToEvt, func(task bus.SendTask) {
 		networkDiag.refreshNodeSent(task)
-	})
+	}, queued())
+
+	eb.Bind(bus.CallEvt, func(call bus.Call) {
+		networkDiag.refreshCall(diag, call, false)
+	}, queued())
+
+	eb.Bind(bus.CallReturnEvt, func(call bus.Call) {
+		networkDiag.refreshCall(diag, call, true)
+	}, queued())
 
 	eb.Bind(bus.AwaitStartEvt, func(id bus.NodeId) {
 		networkDiag.refreshNodeAwait(id)
-	})
+	}, queued())
 
 	eb.Bind(bus.AwaitEndEvt, func(sendTasks []bus.SendTask) {
 		networkDiag.refreshTransmitted(sendTasks)
-	})
+	}, queued())
+
+	eb.Bind(bus.NodeDataChangeEvt, func(data bus.NodeData) {
+		networkDiag.refreshNodeData(data)
+	}, queued())
+
+	eb.Bind(bus.CustomSchemaChangeEvt, func(s bus.CustomSchema) {
+		networkDiag.stateMu.Lock()
+		networkDiag.schema = s.Schema
+		networkDiag.stateMu.Unlock()
+	}, latest())
 
 	eb.Bind(bus.NetworkResizeEvt, func(resizeData bus.NetworkResize) {
 		networkDiag.refreshButtons(eb, wcanvas, resizeData.Cnt)
 		networkDiag.refreshNodes(diag, resizeData.Cnt)
 		networkDiag.refreshConnections(diag, resizeData.Connections)
-	})
+	}, latest())
 
 	eb.Bind(bus.NetworkConnectionsEvt, func(newConnections bus.Connections) {
 		networkDiag.refreshConnections(diag, newConnections)
-	})
+	}, latest())
+
+	eb.Bind(bus.NodePositionsEvt, func(positions bus.NodePositions) {
+		networkDiag.refreshPositions(diag, positions)
+	}, latest())
+
+	eb.Bind(bus.ByzantineChangeEvt, func(b bus.Byzantine) {
+		networkDiag.refreshByzantine(b)
+	}, queued())
+
+	eb.Bind(bus.NodeStateEvt, func(state bus.NodeState) {
+		networkDiag.refreshNodeState(state)
+	}, queued())
 
 	eb.Bind(bus.ContinueNodesEvt, func() {
 		networkDiag.refreshOnContinue(diag)
-	})
+	}, queued())
 
 	eb.Bind(bus.DebugNodesEvt, func() {
+		networkDiag.stateMu.Lock()
+		networkDiag.clearCalls(diag, true)
+		networkDiag.stateMu.Unlock()
 		networkDiag.setNodesRunning(true)
 		networkDiag.Refresh()
-	})
+	}, queued())
 
 	eb.Bind(bus.StopNodesEvt, func() {
 		networkDiag.setNodesRunning(false)
 		networkDiag.Refresh()
-	})
+	}, queued())
 
 	eb.Bind(bus.StartNodesEvt, func() {
 		networkDiag.setNodesRunning(true)
 		networkDiag.setEdgesClean(diag)
 		networkDiag.Refresh()
-	})
+	}, queued())
+
+	eb.Bind(bus.MetricsEvt, func(m bus.Metrics) {
+		networkDiag.refreshTraffic(m)
+	}, latest())
 
 	diag.Refresh()
 	scroll := container.NewScroll(diag)
@@ -104,6 +165,7 @@ func (networkDiag *NetworkDiagram) refreshOnContinue(diag *diagramwidget.Diagram
 	defer networkDiag.stateMu.Unlock()
 
 	networkDiag.setEdgesClean(diag)
+	networkDiag.clearCalls(diag, false)
 	networkDiag.setNodesRunning(true)
 	networkDiag.Refresh()
 }
@@ -116,12 +178,16 @@ func (networkDiag *NetworkDiagram) refreshButtons(eb bus.EventBus,
 	defer networkDiag.stateMu.Unlock()
 
 	buttons := make([]*widget.Button, nodeCnt)
+	dataInputs := make([]*widget.Entry, nodeCnt)
+	positionInputs := make([]*widget.Entry, nodeCnt)
+	byzantines := make([]bus.Byzantine, nodeCnt)
+	copy(byzantines, networkDiag.byzantines)
 	nodeModals := make([]Modal, nodeCnt)
 
 	onPress := func(i int) func() {
 		return func() {
 			p := nodeModals[i]
-			p.Resize(fyne.NewSize(300, 300))
+			p.Resize(fyne.NewSize(400, 550))
 			p.Show()
 		}
 	}
@@ -134,6 +200,10 @@ func (networkDiag *NetworkDiagram) refreshButtons(eb bus.EventBus,
 
 		jsonInput := widget.NewMultiLineEntry()
 		jsonInput.PlaceHolder = `{"foo":"bar"}`
+		// remaining nodes keep their data on resize
+		if i < len(networkDiag.dataInputs) {
+			jsonInput.Text = networkDiag.dataInputs[i].Text
+		}
 		jsonInput.Resize(fyne.NewSize(300, 300))
 		jsonInput.OnChanged = func(s string) {
 
@@ -146,20 +216,92 @@ func (networkDiag *NetworkDiagram) refreshButtons(eb bus.EventBus,
 				return
 			}
 
+			// validate against the schema, if there is any
+			networkDiag.stateMu.Lock()
+			customSchema := networkDiag.schema
+			networkDiag.stateMu.Unlock()
+			if customSchema != nil {
+				if err := schema.Validate(customSchema, data); err != nil {
+					errorLabel.SetText(err.Error())
+					errorLabel.Show()
+					return
+				}
+			}
+
 			changeData := bus.NodeData{TargetId: i, Data: data}
 			evt := bus.Event{Type: bus.NodeDataChangeEvt, Data: changeData}
 			eb.Publish(evt)
 			errorLabel.Hide()
 		}
 
+		// position for manual placement, "x, y"
+		positionInput := widget.NewEntry()
+		positionInput.PlaceHolder = "x, y"
+		if i < len(networkDiag.positionInputs) {
+			positionInput.Text = networkDiag.positionInputs[i].Text
+		}
+		positionInput.OnSubmitted = func(s string) {
+			xs, ys, _ := strings.Cut(s, ",")
+			x, errX := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+			y, errY := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+			if errX != nil || errY != nil {
+				errorLabel.SetText("the position has to be two numbers x, y")
+				errorLabel.Show()
+				return
+			}
+
+			p := bus.NodePosition{NodeId: i, Position: bus.Position{X: x, Y: y}}
+			eb.Publish(bus.Event{Type: bus.NodePositionChangeEvt, Data: p})
+			errorLabel.Hide()
+		}
+
+		// byzantine behaviour, with the alternative code of the code behaviour
+		codeInput := widget.NewMultiLineEntry()
+		codeInput.PlaceHolder = "package main ... func Run(...) any"
+		codeInput.SetText(byzantines[i].Code)
+		behaviours := []string{
+			string(bus.Honest),
+			string(bus.DropByzantine),
+			string(bus.EquivocateByzantine),
+			string(bus.ReplayByzantine),
+			string(bus.CorruptByzantine),
+			string(bus.CodeByzantine),
+		}
+		behaviourSelect := widget.NewSelect(behaviours, nil)
+		behaviourSelect.SetSelected(string(bus.Honest))
+		if byzantines[i].Kind != "" {
+			behaviourSelect.SetSelected(string(byzantines[i].Kind))
+		}
+		publishBehaviour := func() {
+			kind := bus.ByzantineKind(behaviourSelect.Selected)
+			if kind == bus.CodeByzantine && strings.TrimSpace(codeInput.Text) == "" {
+				return
+			}
+			b := bus.Byzantine{NodeId: i, Kind: kind, Code: codeInput.Text}
+			eb.Publish(bus.Event{Type: bus.ByzantineChangeEvt, Data: b})
+		}
+		behaviourSelect.OnChanged = func(string) { publishBehaviour() }
+		codeInput.OnChanged = func(string) {
+			if behaviourSelect.Selected == string(bus.CodeByzantine) {
+				publishBehaviour()
+			}
+		}
+
 		vstack := container.NewVBox(
 			label,
 			jsonInput,
+			widget.NewLabel("Position (manual placement) : "),
+			positionInput,
+			widget.NewLabel("Byzantine behaviour : "),
+			behaviourSelect,
+			codeInput,
 			errorLabel)
 
 		popup := NewModal(vstack, wcanvas)
 		popup.Hide()
 		nodeModals[i] = popup
+		dataInputs[i] = jsonInput
+		positionInputs[i] = positionInput
 
 		// init buttons
 		nodeName := "Node " + strconv.Itoa(i)
@@ -168,6 +310,31 @@ func (networkDiag *NetworkDiagram) refreshButtons(eb bus.EventBus,
 	}
 
 	networkDiag.buttons = buttons
+	networkDiag.dataInputs = dataInputs
+	networkDiag.positionInputs = positionInputs
+	networkDiag.byzantines = byzantines
+}
+
+// shows custom data that was changed elsewhere e.g. generated from a template
+func (networkDiag *NetworkDiagram) refreshNodeData(data bus.NodeData) {
+	networkDiag.stateMu.Lock()
+	if data.TargetId >= len(networkDiag.dataInputs) {
+		networkDiag.stateMu.Unlock()
+		return
+	}
+	input := networkDiag.dataInputs[data.TargetId]
+	networkDiag.stateMu.Unlock()
+
+	// the input publishes changes itself, so only set differing data
+	var current any
+	if err := json.Unmarshal([]byte(input.Text), &current); err == nil && reflect.DeepEqual(current, data.Data) {
+		return
+	}
+	b, err := json.Marshal(data.Data)
+	if err != nil {
+		return
+	}
+	input.SetText(string(b))
 }
 
 // refresh nodes depending on the new node count
@@ -177,7 +344,7 @@ func (networkDiag *NetworkDiagram) refreshNodes(
 	networkDiag.stateMu.Lock()
 	defer networkDiag.stateMu.Unlock()
 
-	points := placePointsOnCircle(nodeCnt)
+	points := networkDiag.nodePoints(nodeCnt)
 
 	// TODO : check if count changed
 	for _, n := range networkDiag.nodes {
@@ -186,35 +353,72 @@ func (networkDiag *NetworkDiagram) refreshNodes(
 	networkDiag.nodes = networkDiag.nodes[:0]
 
 	for i, p := range points {
-		// needs an inital size because its not set for the widget on the
-		// initial pass
-		diagWidth := diag.Size().Width
-		if diagWidth == 0 {
-			diagWidth = 800
-		}
-
-		diagHeight := diag.Size().Height
-		if diagHeight == 0 {
-			diagHeight = 1200
-		}
-
-		ratiow := diagWidth / 100
-		ratioh := diagHeight / 100
-
-		// draw node
-		x := ratiow * float32(p.x*.5)
-		y := ratioh * float32(p.y*.5)
-
 		nodeName := "Node" + strconv.Itoa(i)
 		nodeButton := networkDiag.buttons[i]
 		diagNode := diagramwidget.NewDiagramNode(diag, nodeButton, "Id:"+nodeName)
-		diagNode.Move(fyne.Position{X: x, Y: y})
+		diagNode.Move(diagramPosition(diag, p))
 		newNode := node{diagNode, false, false}
 		networkDiag.nodes = append(networkDiag.nodes, newNode)
 	}
 	networkDiag.Refresh()
 }
 
+// moves the nodes to their geographic positions
+func (networkDiag *NetworkDiagram) refreshPositions(
+	diag *diagramwidget.DiagramWidget, positions bus.NodePositions) {
+
+	networkDiag.stateMu.Lock()
+	defer networkDiag.stateMu.Unlock()
+
+	networkDiag.positions = positions
+	for i, p := range networkDiag.nodePoints(len(networkDiag.nodes)) {
+		n := networkDiag.nodes[i]
+		diag.DisplaceNode(n, diagramPosition(diag, p).Subtract(n.Position()))
+	}
+	for i, p := range positions.Positions {
+		if i < len(networkDiag.positionInputs) {
+			text := strconv.FormatFloat(p.X, 'f', 1, 64) + ", " + strconv.FormatFloat(p.Y, 'f', 1, 64)
+			networkDiag.positionInputs[i].SetText(text)
+		}
+	}
+	networkDiag.Refresh()
+}
+
+// the points of the nodes in a 100x100 square, on a circle unless every node
+// has a position. Requires the state lock to be held.
+func (networkDiag *NetworkDiagram) nodePoints(nodeCnt int) []point {
+	positions := networkDiag.positions
+	if len(positions.Positions) != nodeCnt || positions.Size <= 0 {
+		return placePointsOnCircle(nodeCnt)
+	}
+
+	points := make([]point, nodeCnt)
+	for i, p := range positions.Positions {
+		points[i] = point{100 * p.X / positions.Size, 100 * p.Y / positions.Size}
+	}
+	return points
+}
+
+// converts a point of the 100x100 square to a position within the diagram
+func diagramPosition(diag *diagramwidget.DiagramWidget, p point) fyne.Position {
+	// needs an inital size because its not set for the widget on the
+	// initial pass
+	diagWidth := diag.Size().Width
+	if diagWidth == 0 {
+		diagWidth = 800
+	}
+
+	diagHeight := diag.Size().Height
+	if diagHeight == 0 {
+		diagHeight = 1200
+	}
+
+	ratiow := diagWidth / 100
+	ratioh := diagHeight / 100
+
+	return fyne.Position{X: ratiow * float32(p.x*.5), Y: ratioh * float32(p.y*.5)}
+}
+
 // recreates all links depending on the given connections
 func (networkDiag *NetworkDiagram) refreshConnections(
 	diag *diagramwidget.DiagramWidget, connections bus.Connections) {
@@ -235,6 +439,41 @@ func (networkDiag *NetworkDiagram) refreshConnections(
 	networkDiag.Refresh()
 }
 
+// marks nodes that deviate from the protocol
+func (networkDiag *NetworkDiagram) refreshByzantine(b bus.Byzantine) {
+	networkDiag.stateMu.Lock()
+	defer networkDiag.stateMu.Unlock()
+
+	if b.NodeId < 0 || b.NodeId >= len(networkDiag.byzantines) {
+		return
+	}
+	networkDiag.byzantines[b.NodeId] = b
+	if b.NodeId < len(networkDiag.nodes) {
+		networkDiag.setInnerObj(bus.NodeId(b.NodeId))
+		networkDiag.Refresh()
+	}
+}
+
+// shows whether a node joined or left the network
+func (networkDiag *NetworkDiagram) refreshNodeState(state bus.NodeState) {
+	networkDiag.stateMu.Lock()
+	defer networkDiag.stateMu.Unlock()
+
+	if networkDiag.offline == nil {
+		networkDiag.offline = make(map[int]bool)
+	}
+	if state.Online {
+		delete(networkDiag.offline, state.NodeId)
+	} else {
+		networkDiag.offline[state.NodeId] = true
+	}
+
+	if state.NodeId < len(networkDiag.nodes) {
+		networkDiag.setInnerObj(bus.NodeId(state.NodeId))
+		networkDiag.Refresh()
+	}
+}
+
 // when a node has sent data (no matter whether it was transmitted successfully)
 func (networkDiag *NetworkDiagram) refreshNodeSent(task bus.SendTask) {
 	networkDiag.stateMu.Lock()
@@ -283,6 +522,62 @@ func (networkDiag *NetworkDiagram) refreshTransmitted(sendTasks []bus.SendTask)
 	networkDiag.Refresh()
 }
 
+// when a node called another one or the response arrived, the arrows of a
+// call stay until the response has been seen
+func (networkDiag *NetworkDiagram) refreshCall(diag *diagramwidget.DiagramWidget, call bus.Call, isReturn bool) {
+	networkDiag.stateMu.Lock()
+	defer networkDiag.stateMu.Unlock()
+
+	if call.From >= len(networkDiag.nodes) || call.To >= len(networkDiag.nodes) {
+		return
+	}
+	if networkDiag.calls == nil {
+		networkDiag.calls = make(map[int]*callLinks)
+	}
+	cl, ok := networkDiag.calls[call.Id]
+	if !ok {
+		cl = &callLinks{}
+		networkDiag.calls[call.Id] = cl
+	}
+
+	label, linkColor := "call #", callColor
+	if isReturn {
+		label, linkColor = "return #", returnColor
+		cl.returned = true
+	}
+	label += strconv.Itoa(call.Id)
+
+	link := diagramwidget.NewDiagramLink(diag, label)
+	link.SetSourcePad(networkDiag.nodes[call.From].GetEdgePad())
+	link.SetTargetPad(networkDiag.nodes[call.To].GetEdgePad())
+	link.AddTargetDecoration(diagramwidget.NewArrowhead())
+	dataStr, _ := json.Marshal(call.Data)
+	link.AddMidpointAnchoredText("call", label+" : "+string(dataStr))
+
+	props := link.GetProperties()
+	props.ForegroundColor = linkColor
+	link.SetProperties(props)
+	cl.links = append(cl.links, link)
+
+	networkDiag.nodes[call.From].isPaused = true
+	networkDiag.setInnerObj(bus.NodeId(call.From))
+	networkDiag.Refresh()
+}
+
+// removes the arrows of returned calls, or of all calls
+// requires the state lock to be held
+func (networkDiag *NetworkDiagram) clearCalls(diag *diagramwidget.DiagramWidget, all bool) {
+	for id, cl := range networkDiag.calls {
+		if !cl.returned && !all {
+			continue
+		}
+		for _, l := range cl.links {
+			diag.RemoveElement(l.GetDiagramElementID())
+		}
+		delete(networkDiag.calls, id)
+	}
+}
+
 // change the UI such that all nodes are viewed as running
 func (networkDiag *NetworkDiagram) setNodesRunning(isRunning bool) {
 	for nid := range networkDiag.nodes {
@@ -309,9 +604,50 @@ func (networkDiag *NetworkDiagram) createLink(c bus.Connection, diag *diagramwid
 	link.SetTargetPad(networkDiag.nodes[c.To].GetEdgePad())
 	link.AddTargetDecoration(diagramwidget.NewArrowhead())
 	edge := edge{link, c.From, c.To}
+	networkDiag.setEdgeHeat(edge)
 	networkDiag.edges = append(networkDiag.edges, edge)
 }
 
+// colour and widen edges depending on how many messages they transmitted
+func (networkDiag *NetworkDiagram) refreshTraffic(m bus.Metrics) {
+	networkDiag.stateMu.Lock()
+	defer networkDiag.stateMu.Unlock()
+
+	networkDiag.traffic = make(map[bus.Connection]int)
+	networkDiag.maxTraffic = 0
+	for _, e := range m.Edges {
+		networkDiag.traffic[e.Connection] = e.Messages
+		if e.Messages > networkDiag.maxTraffic {
+			networkDiag.maxTraffic = e.Messages
+		}
+	}
+
+	for _, e := range networkDiag.edges {
+		networkDiag.setEdgeHeat(e)
+	}
+	networkDiag.Refresh()
+}
+
+// requires the state lock to be held
+func (networkDiag *NetworkDiagram) setEdgeHeat(e edge) {
+	heat := float32(0)
+	if networkDiag.maxTraffic > 0 {
+		c := bus.Connection{From: e.from, To: e.to}
+		heat = float32(networkDiag.traffic[c]) / float32(networkDiag.maxTraffic)
+	}
+
+	props := e.GetProperties()
+	props.StrokeWidth = 1 + 5*heat
+	props.ForegroundColor = color.RGBA{
+		R: uint8(128 + 127*heat),
+		G: uint8(128 - 96*heat),
+		B: uint8(128 - 96*heat),
+		A: 255,
+	}
+	e.SetProperties(props)
+	e.Refresh()
+}
+
 // sets the inner object of the specified node (including statuses etc.)
 func (networkDiag *NetworkDiagram) setInnerObj(nodeId bus.NodeId) {
 	innerObj := container.NewHBox()
@@ -320,8 +656,22 @@ func (networkDiag *NetworkDiagram) setInnerObj(nodeId bus.NodeId) {
 	if networkDiag.nodes[nodeId].isPaused {
 		runningIcon = stopIcon
 	}
+	if networkDiag.offline[int(nodeId)] {
+		runningIcon = offlineIcon
+	}
 	innerObj.Add(runningIcon)
 
+	if networkDiag.offline[int(nodeId)] {
+		innerObj.Add(widget.NewLabel("Offline"))
+	}
+
+	if int(nodeId) < len(networkDiag.byzantines) {
+		if b := networkDiag.byzantines[nodeId]; b.Kind != "" && b.Kind != bus.Honest {
+			innerObj.Add(byzantineIcon)
+			innerObj.Add(widget.NewLabel("Byzantine : " + string(b.Kind)))
+		}
+	}
+
 	if networkDiag.nodes[nodeId].isAwaiting {
 		innerObj.Add(widget.NewLabel("Awaiting"))
 	}
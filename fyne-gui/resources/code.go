@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"distributed-sys-emulator/sim"
 	"fmt"
 	"time"
 )
@@ -10,15 +11,15 @@ type sendFunc func(targetId int, data any) int
 type awaitFunc func(int) []any
 
 // wait for ctx.Done to exit gracefully
-// use fSend and fAwait to communicate between nodes
+// use fSend and fAwait or the sim package to communicate between nodes
 func Run(ctx context.Context, fSend sendFunc, fAwait awaitFunc) any {
-	fmt.Println("custom data ", ctx.Value("custom"))
-	fmt.Println("out-neighbors ", ctx.Value("out-neighbors"))
-	fmt.Println("in-neighbors ", ctx.Value("in-neighbors"))
-	fmt.Println("id ", ctx.Value("id"))
+	fmt.Println("custom data ", sim.CustomValue(ctx))
+	fmt.Println("out-neighbors ", sim.OutNeighbors(ctx))
+	fmt.Println("in-neighbors ", sim.InNeighbors(ctx))
+	fmt.Println("id ", sim.ID(ctx))
 
 	res := struct{ foo string }{foo: "bar"}
-	inNeighbors, ok := ctx.Value("in-neighbors").([]int)
+	inNeighbors := sim.InNeighbors(ctx)
 	go func() {
 		for {
 			select {
@@ -26,26 +27,21 @@ func Run(ctx context.Context, fSend sendFunc, fAwait awaitFunc) any {
 				return
 			default:
 				if len(inNeighbors) > 0 {
-					awaitRes := fAwait(len(inNeighbors))
+					awaitRes := sim.Await(ctx, len(inNeighbors))
 					fmt.Println("awaitRes ", awaitRes)
 				}
 			}
 		}
 	}()
 
-	outNeighbors, ok := ctx.Value("out-neighbors").([]int)
 	for {
 		select {
 		case <-ctx.Done():
 			return res
 		default:
 			time.Sleep(time.Second * 1)
-			if ok {
-				for _, c := range outNeighbors {
-					fmt.Println("send")
-					fSend(c, "data")
-				}
-			}
+			fmt.Println("send")
+			sim.Broadcast(ctx, "data")
 		}
 	}
 }
@@ -1,22 +1,132 @@
 package main
 
 import (
+	"context"
 	"distributed-sys-emulator/bus"
 	"distributed-sys-emulator/core"
 	fynegui "distributed-sys-emulator/fyne-gui"
 	"distributed-sys-emulator/log"
+	"errors"
 	"flag"
+	"net"
+	"net/http"
+	"os"
 )
 
+var benchFlag = flag.String("bench", "", "run the benchmark configured in the given json file without the gui")
+var benchOutFlag = flag.String("bench-out", "report.csv", "file to write the benchmark report to, either .csv or .json")
+var recordFlag = flag.String("record", "", "file to record the events of the session or benchmark to")
+var replayFlag = flag.String("replay", "", "replay the events recorded in the given file in the gui, instead of running a network")
+var replaySpeedFlag = flag.Float64("replay-speed", 1, "how many times faster than recorded to replay, 0 replays without delay")
+var serveFlag = flag.String("serve", "", "address to expose the event bus on over http, e.g. localhost:8080")
+var servePublicFlag = flag.Bool("serve-public", false, "allow -serve on addresses other hosts can reach, the bridge has no authentication")
+var workerFlag = flag.String("worker", "", "internal : run as the worker process of a node, connecting to the given address")
+var workerIdFlag = flag.Int("worker-id", 0, "internal : id of the node the worker process runs")
+
 func main() {
 	flag.Parse()
 
+	if *workerFlag != "" {
+		if err := core.RunWorker(*workerFlag, *workerIdFlag); err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *benchFlag != "" {
+		runBenchmark()
+		return
+	}
+
 	eb := bus.NewEventbus()
 
-	log.Info("Init Core")
-	network := core.NewNetwork(eb)
-	network.Init(eb)
+	if *replayFlag != "" {
+		go replay(eb)
+	} else {
+		log.Info("Init Core")
+		network := core.NewNetwork(eb)
+		network.Init(eb)
+	}
+
+	if *recordFlag != "" {
+		f, err := os.Create(*recordFlag)
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		rec := bus.NewRecording(f)
+		defer rec.Record(eb).Unsubscribe()
+	}
+
+	if *serveFlag != "" {
+		if !*servePublicFlag && !isLoopback(*serveFlag) {
+			log.Error(errors.New("refusing to serve the event bus on a non-loopback address without -serve-public"), " ", *serveFlag)
+			os.Exit(1)
+		}
+		go serve(eb)
+	}
 
 	log.Info("Run GUI")
 	fynegui.RunGUI(eb)
 }
+
+func serve(eb bus.EventBus) {
+	log.Info("Serve the event bus on ", *serveFlag)
+	if err := http.ListenAndServe(*serveFlag, bus.NewBridge(eb)); err != nil {
+		log.Error(err)
+	}
+}
+
+// whether only the local host can reach the address, an empty host listens on
+// all interfaces
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func replay(eb bus.EventBus) {
+	f, err := os.Open(*replayFlag)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer f.Close()
+
+	log.Info("Replay ", *replayFlag)
+	if err := bus.Replay(context.Background(), eb, f, *replaySpeedFlag); err != nil {
+		log.Error(err)
+		return
+	}
+	log.Info("Replay finished")
+}
+
+func runBenchmark() {
+	config, err := core.LoadBenchmarkConfig(*benchFlag)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	if *recordFlag != "" {
+		config.Record = *recordFlag
+	}
+
+	results, err := core.RunBenchmark(config)
+	if err != nil {
+		log.Error(err)
+	}
+
+	if err := core.WriteBenchmarkReport(*benchOutFlag, results); err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	log.Info("Wrote benchmark report to ", *benchOutFlag)
+}
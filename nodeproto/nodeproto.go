@@ -0,0 +1,115 @@
+package nodeproto
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+/* Line delimited json protocol between the emulator and a node running outside
+* of the emulators process. Every line is one Frame, the Type defines which of
+* its fields are set. Workers of the process backend connect over tcp, those of
+* the native and external backends use their stdin and stdout, so a node may be
+* written in any language.
+*
+* emulator -> node :
+*   init      Id, Custom, OutNeighbors, InNeighbors (and Code for go workers)
+*   sent      Seq, Cnt          reply to send, Cnt nodes have been reached
+*   messages  Seq, Messages     reply to await
+*   returned  Seq, Data, Error  reply to call
+*   request   Seq, Id, Data     a call of node Id, to be answered by a response
+*   topology  OutNeighbors, InNeighbors
+*                               the connections of the node changed during the run
+*   stop      the node should return from Run as soon as possible
+*
+* node -> emulator :
+*   hello     Id                identifies a connection, only required over tcp
+*   send      Seq, To, Data
+*   await     Seq, Cnt
+*   call      Seq, To, Data
+*   handle    the node serves calls from now on
+*   response  Seq, Data, Error  reply to request
+*   log       Text              output to show in the console
+*   result    Data, Error       Run returned, the node may exit afterwards
+*
+* Requests (send, await, call and request) carry a sequence number which is
+* echoed in the reply, so several requests may be pending at once. Both sides
+* number their requests independently. Fields holding their zero value are
+* omitted, e.g. the id of node 0 or empty neighbor lists.
+ */
+
+type FrameType string
+
+const (
+	Init     FrameType = "init"
+	Sent     FrameType = "sent"
+	Messages FrameType = "messages"
+	Returned FrameType = "returned"
+	Request  FrameType = "request"
+	Topology FrameType = "topology"
+	Stop     FrameType = "stop"
+	Hello    FrameType = "hello"
+	Send     FrameType = "send"
+	Await    FrameType = "await"
+	Call     FrameType = "call"
+	Handle   FrameType = "handle"
+	Response FrameType = "response"
+	Log      FrameType = "log"
+	Result   FrameType = "result"
+)
+
+type Message struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	Data any `json:"data"`
+}
+
+type Frame struct {
+	Type         FrameType `json:"type"`
+	Seq          int       `json:"seq,omitempty"`
+	Id           int       `json:"id,omitempty"`
+	Custom       any       `json:"custom,omitempty"`
+	OutNeighbors []int     `json:"out-neighbors,omitempty"`
+	InNeighbors  []int     `json:"in-neighbors,omitempty"`
+	Code         string    `json:"code,omitempty"`
+	To           int       `json:"to,omitempty"`
+	Cnt          int       `json:"cnt,omitempty"`
+	Data         any       `json:"data,omitempty"`
+	Messages     []Message `json:"messages,omitempty"`
+	Text         string    `json:"text,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Conn reads and writes frames, writes are safe for concurrent use
+type Conn struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	dec *bufio.Scanner
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	dec := bufio.NewScanner(r)
+	dec.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &Conn{enc: json.NewEncoder(w), dec: dec}
+}
+
+func (c *Conn) Write(f Frame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc.Encode(f)
+}
+
+// Read blocks until the next frame, returns io.EOF once the connection closed
+func (c *Conn) Read() (Frame, error) {
+	var f Frame
+	if !c.dec.Scan() {
+		if err := c.dec.Err(); err != nil {
+			return f, err
+		}
+		return f, io.EOF
+	}
+
+	err := json.Unmarshal(c.dec.Bytes(), &f)
+	return f, err
+}
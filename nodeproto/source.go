@@ -0,0 +1,8 @@
+package nodeproto
+
+import _ "embed"
+
+// Source of the package, to be copied into generated modules
+//
+//go:embed nodeproto.go
+var Source []byte
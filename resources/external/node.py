@@ -0,0 +1,64 @@
+#!/usr/bin/env python3
+"""A node for the external backend, run it with the command `python3 node.py`.
+
+Every node sends its value to its out-neighbors and returns the largest value
+it knows of, its own or one it received. Frames are exchanged as lines of json
+over stdin and stdout, see nodeproto/nodeproto.go. Anything printed to stdout
+would break the protocol, so output is sent as a log frame instead.
+"""
+
+import json
+import sys
+
+seq = 0
+
+
+def write(frame):
+    sys.stdout.write(json.dumps(frame) + "\n")
+    sys.stdout.flush()
+
+
+def read():
+    line = sys.stdin.readline()
+    if not line:
+        sys.exit(0)
+    return json.loads(line)
+
+
+def request(frame):
+    """Sends a request and waits for its reply, the node stops on stop."""
+    global seq
+    seq += 1
+    frame["seq"] = seq
+    write(frame)
+    while True:
+        reply = read()
+        if reply["type"] == "stop":
+            sys.exit(0)
+        if reply.get("seq", 0) == seq:
+            return reply
+
+
+def main():
+    init = read()
+    node_id = init.get("id", 0)
+    custom = init.get("custom") or {}
+    value = custom.get("value", node_id) if isinstance(custom, dict) else node_id
+
+    for peer in init.get("out-neighbors", []):
+        request({"type": "send", "to": peer, "data": value})
+
+    cnt = len(init.get("in-neighbors", []))
+    received = request({"type": "await", "cnt": cnt}).get("messages", [])
+    largest = max([value] + [m.get("data", 0) for m in received])
+
+    write({"type": "log", "text": "node %d received %d messages\n" % (node_id, len(received))})
+    write({"type": "result", "data": largest})
+
+    # keep running until stopped
+    while read()["type"] != "stop":
+        pass
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,72 @@
+#!/usr/bin/env python3
+"""A client of the event bus bridge, start the emulator with `-serve localhost:8080`
+and run `python3 client.py localhost:8080`.
+
+It starts the nodes with the code in the editor, stops them once every node
+returned and prints their results. Events are streamed from /events as
+server-sent events and control events are posted to /publish, see bus/bridge.go.
+"""
+
+import json
+import queue
+import sys
+import threading
+import urllib.request
+
+address = sys.argv[1] if len(sys.argv) > 1 else "localhost:8080"
+base = "http://" + address
+
+
+def publish(etype, data=None):
+    body = json.dumps({"type": etype, "data": data}).encode()
+    headers = {"Content-Type": "application/json"}
+    request = urllib.request.Request(base + "/publish", body, headers, method="POST")
+    urllib.request.urlopen(request).close()
+
+
+def stream(etypes, events):
+    """Puts every event of the given types into the queue."""
+    query = "&".join("type=" + etype for etype in etypes)
+    with urllib.request.urlopen(base + "/events?" + query) as response:
+        for line in response:
+            line = line.decode().strip()
+            if line.startswith("data: "):
+                events.put(json.loads(line[len("data: "):]))
+
+
+def main():
+    events = queue.Queue()
+    etypes = ["network-resize", "node-finished", "node-output"]
+    threading.Thread(target=stream, args=(etypes, events), daemon=True).start()
+
+    # the most recent event of every type is sent on subscribing, the network
+    # size is taken from it while the stale results are skipped
+    cnt = 0
+    try:
+        while True:
+            e = events.get(timeout=1)
+            if e["type"] == "network-resize":
+                cnt = e["data"]["Cnt"]
+    except queue.Empty:
+        pass
+
+    publish("start-nodes")
+    finished = set()
+    while len(finished) < cnt:
+        e = events.get()
+        if e["type"] == "node-finished":
+            finished.add(e["data"])
+
+    publish("stop-nodes")
+    results = {}
+    while len(results) < cnt:
+        e = events.get()
+        if e["type"] == "node-output":
+            results[e["data"]["NodeId"]] = e["data"]["Result"]
+
+    for node_id in sorted(results):
+        print("node %d returned %s" % (node_id, json.dumps(results[node_id])))
+
+
+if __name__ == "__main__":
+    main()
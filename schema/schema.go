@@ -0,0 +1,515 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+/* Validates decoded json (as returned by json.Unmarshal into an any) against
+* a subset of JSON Schema. Supported keywords :
+*
+*   type                    "null", "boolean", "object", "array", "number",
+*                           "integer", "string" or a list of them
+*   enum, const
+*   properties, required, additionalProperties (bool or schema)
+*   items, minItems, maxItems, uniqueItems
+*   minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+*   minLength, maxLength, pattern
+*   allOf, anyOf, oneOf, not
+*
+* Other keywords are ignored. A schema of true or {} accepts everything.
+ */
+
+// Parse decodes a schema and checks that it only uses supported keywords
+// correctly, the error lists every invalid keyword
+func Parse(text string) (any, error) {
+	var s any
+	if err := json.Unmarshal([]byte(text), &s); err != nil {
+		return nil, err
+	}
+	if errs := schemaErrors(s, ""); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return s, nil
+}
+
+// checks every keyword on its own by validating null against it, and the
+// subschemas of a keyword separately
+func schemaErrors(schema any, path string) []error {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		if _, err := validate(schema, nil, path); err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		switch value := s[key].(type) {
+		case map[string]any:
+			switch key {
+			case "items":
+				errs = append(errs, schemaErrors(value, path+"/items")...)
+				continue
+			case "additionalProperties", "not":
+				errs = append(errs, schemaErrors(value, path)...)
+				continue
+			case "properties":
+				names := make([]string, 0, len(value))
+				for name := range value {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					errs = append(errs, schemaErrors(value[name], path+"/"+name)...)
+				}
+				continue
+			}
+		case []any:
+			switch key {
+			case "allOf", "anyOf", "oneOf":
+				for _, sub := range value {
+					errs = append(errs, schemaErrors(sub, path)...)
+				}
+				continue
+			}
+		}
+
+		if _, err := validateObject(map[string]any{key: s[key]}, nil, path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ValidationError describes where and why data does not match a schema
+type ValidationError struct {
+	Path   string // json pointer to the invalid value, empty for the root
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + " : " + e.Reason
+}
+
+// Validate returns nil if data matches the schema, a *ValidationError if it
+// does not or another error if the schema is invalid
+func Validate(schema, data any) error {
+	verr, err := validate(schema, normalize(data), "")
+	if err != nil {
+		return err
+	}
+	if verr != nil {
+		return verr
+	}
+	return nil
+}
+
+// brings data into the form json.Unmarshal produces e.g. ints to float64
+func normalize(data any) any {
+	switch data.(type) {
+	case nil, bool, float64, string, []any, map[string]any:
+		return data
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+	var res any
+	if err := json.Unmarshal(b, &res); err != nil {
+		return data
+	}
+	return res
+}
+
+// the first result is the reason data is invalid, the second one is set if
+// the schema itself is invalid
+func validate(schema, data any, path string) (*ValidationError, error) {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return invalid(path, "no value is allowed"), nil
+		}
+		return nil, nil
+	case map[string]any:
+		return validateObject(s, data, path)
+	}
+	return nil, fmt.Errorf("schema at %q has to be an object or a boolean", path)
+}
+
+func invalid(path, format string, args ...any) *ValidationError {
+	return &ValidationError{Path: path, Reason: fmt.Sprintf(format, args...)}
+}
+
+// checks every supported keyword in a fixed order, so errors are stable
+func validateObject(s map[string]any, data any, path string) (*ValidationError, error) {
+	keywords := []func(map[string]any, any, string) (*ValidationError, error){
+		validateType,
+		validateEnum,
+		validateNumber,
+		validateString,
+		validateArray,
+		validateProperties,
+		validateCombinations,
+	}
+
+	for _, k := range keywords {
+		verr, err := k(s, data, path)
+		if verr != nil || err != nil {
+			return verr, err
+		}
+	}
+	return nil, nil
+}
+
+func typeOf(data any) string {
+	switch d := data.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if d == math.Trunc(d) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", data)
+}
+
+func validateType(s map[string]any, data any, path string) (*ValidationError, error) {
+	t, ok := s["type"]
+	if !ok {
+		return nil, nil
+	}
+
+	var types []string
+	switch t := t.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, e := range t {
+			name, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("type at %q has to be a string or a list of strings", path)
+			}
+			types = append(types, name)
+		}
+	default:
+		return nil, fmt.Errorf("type at %q has to be a string or a list of strings", path)
+	}
+
+	for _, name := range types {
+		switch name {
+		case "null", "boolean", "object", "array", "number", "string", "integer":
+		default:
+			return nil, fmt.Errorf("unknown type %q at %q", name, path)
+		}
+	}
+
+	actual := typeOf(data)
+	for _, name := range types {
+		if name == actual || name == "number" && actual == "integer" {
+			return nil, nil
+		}
+	}
+	return invalid(path, "expected %v, got %s", types, actual), nil
+}
+
+func validateEnum(s map[string]any, data any, path string) (*ValidationError, error) {
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, data) {
+		return invalid(path, "expected %v", c), nil
+	}
+
+	e, ok := s["enum"]
+	if !ok {
+		return nil, nil
+	}
+	values, ok := e.([]any)
+	if !ok {
+		return nil, fmt.Errorf("enum at %q has to be a list", path)
+	}
+	for _, v := range values {
+		if reflect.DeepEqual(v, data) {
+			return nil, nil
+		}
+	}
+	return invalid(path, "expected one of %v", values), nil
+}
+
+func number(s map[string]any, key, path string) (float64, bool, error) {
+	v, ok := s[key]
+	if !ok {
+		return 0, false, nil
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return 0, false, fmt.Errorf("%s at %q has to be a number", key, path)
+	}
+	return f, true, nil
+}
+
+func validateNumber(s map[string]any, data any, path string) (*ValidationError, error) {
+	d, isNumber := data.(float64)
+
+	checks := []struct {
+		key     string
+		failed  func(limit float64) bool
+		message string
+	}{
+		{"minimum", func(l float64) bool { return d < l }, "%v is less than the minimum %v"},
+		{"maximum", func(l float64) bool { return d > l }, "%v is greater than the maximum %v"},
+		{"exclusiveMinimum", func(l float64) bool { return d <= l }, "%v is not greater than %v"},
+		{"exclusiveMaximum", func(l float64) bool { return d >= l }, "%v is not less than %v"},
+		{"multipleOf", func(l float64) bool { return l != 0 && math.Mod(d, l) != 0 }, "%v is not a multiple of %v"},
+	}
+
+	for _, c := range checks {
+		limit, ok, err := number(s, c.key, path)
+		if err != nil {
+			return nil, err
+		}
+		if ok && isNumber && c.failed(limit) {
+			return invalid(path, c.message, d, limit), nil
+		}
+	}
+	return nil, nil
+}
+
+func validateString(s map[string]any, data any, path string) (*ValidationError, error) {
+	d, isString := data.(string)
+	length := len([]rune(d))
+
+	if min, ok, err := number(s, "minLength", path); err != nil {
+		return nil, err
+	} else if ok && isString && float64(length) < min {
+		return invalid(path, "%q is shorter than %v", d, min), nil
+	}
+
+	if max, ok, err := number(s, "maxLength", path); err != nil {
+		return nil, err
+	} else if ok && isString && float64(length) > max {
+		return invalid(path, "%q is longer than %v", d, max), nil
+	}
+
+	p, ok := s["pattern"]
+	if !ok {
+		return nil, nil
+	}
+	pattern, ok := p.(string)
+	if !ok {
+		return nil, fmt.Errorf("pattern at %q has to be a string", path)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("pattern at %q : %w", path, err)
+	}
+	if isString && !re.MatchString(d) {
+		return invalid(path, "%q does not match %q", d, pattern), nil
+	}
+	return nil, nil
+}
+
+func validateArray(s map[string]any, data any, path string) (*ValidationError, error) {
+	d, isArray := data.([]any)
+
+	if min, ok, err := number(s, "minItems", path); err != nil {
+		return nil, err
+	} else if ok && isArray && float64(len(d)) < min {
+		return invalid(path, "has %d items, expected at least %v", len(d), min), nil
+	}
+
+	if max, ok, err := number(s, "maxItems", path); err != nil {
+		return nil, err
+	} else if ok && isArray && float64(len(d)) > max {
+		return invalid(path, "has %d items, expected at most %v", len(d), max), nil
+	}
+
+	if unique, _ := s["uniqueItems"].(bool); unique && isArray {
+		for i := range d {
+			for j := i + 1; j < len(d); j++ {
+				if reflect.DeepEqual(d[i], d[j]) {
+					return invalid(path, "items %d and %d are equal", i, j), nil
+				}
+			}
+		}
+	}
+
+	items, ok := s["items"]
+	if !ok {
+		return nil, nil
+	}
+	if !isArray {
+		// still check the items schema itself
+		_, err := validate(items, nil, path+"/items")
+		return nil, err
+	}
+	for i, item := range d {
+		verr, err := validate(items, item, path+"/"+strconv.Itoa(i))
+		if verr != nil || err != nil {
+			return verr, err
+		}
+	}
+	return nil, nil
+}
+
+func validateProperties(s map[string]any, data any, path string) (*ValidationError, error) {
+	d, isObject := data.(map[string]any)
+
+	if r, ok := s["required"]; ok {
+		required, ok := r.([]any)
+		if !ok {
+			return nil, fmt.Errorf("required at %q has to be a list", path)
+		}
+		for _, name := range required {
+			if _, ok := d[fmt.Sprint(name)]; isObject && !ok {
+				return invalid(path, "%v is required", name), nil
+			}
+		}
+	}
+
+	var properties map[string]any
+	if p, ok := s["properties"]; ok {
+		properties, ok = p.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("properties at %q has to be an object", path)
+		}
+	}
+
+	// sorted so the first error is deterministic
+	var names []string
+	for name := range properties {
+		names = append(names, name)
+	}
+	for name := range d {
+		if _, ok := properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	additional, hasAdditional := s["additionalProperties"]
+	for _, name := range names {
+		propPath := path + "/" + name
+		value, present := d[name]
+
+		sub, ok := properties[name]
+		if !ok {
+			if !hasAdditional {
+				continue
+			}
+			sub = additional
+		}
+
+		// schemas of missing properties are only checked for their validity
+		if !isObject || !present {
+			if _, err := validate(sub, nil, propPath); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		verr, err := validate(sub, value, propPath)
+		if verr != nil || err != nil {
+			return verr, err
+		}
+	}
+	return nil, nil
+}
+
+func validateCombinations(s map[string]any, data any, path string) (*ValidationError, error) {
+	list := func(key string) ([]any, error) {
+		v, ok := s[key]
+		if !ok {
+			return nil, nil
+		}
+		l, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s at %q has to be a list", key, path)
+		}
+		return l, nil
+	}
+
+	allOf, err := list("allOf")
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range allOf {
+		verr, err := validate(sub, data, path)
+		if verr != nil || err != nil {
+			return verr, err
+		}
+	}
+
+	anyOf, err := list("anyOf")
+	if err != nil {
+		return nil, err
+	}
+	matches, err := countMatches(anyOf, data, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(anyOf) > 0 && matches == 0 {
+		return invalid(path, "matches none of anyOf"), nil
+	}
+
+	oneOf, err := list("oneOf")
+	if err != nil {
+		return nil, err
+	}
+	matches, err = countMatches(oneOf, data, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(oneOf) > 0 && matches != 1 {
+		return invalid(path, "matches %d of oneOf, expected exactly one", matches), nil
+	}
+
+	if not, ok := s["not"]; ok {
+		verr, err := validate(not, data, path)
+		if err != nil {
+			return nil, err
+		}
+		if verr == nil {
+			return invalid(path, "must not match the schema of not"), nil
+		}
+	}
+	return nil, nil
+}
+
+func countMatches(schemas []any, data any, path string) (int, error) {
+	matches := 0
+	for _, sub := range schemas {
+		verr, err := validate(sub, data, path)
+		if err != nil {
+			return 0, err
+		}
+		if verr == nil {
+			matches++
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,88 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["value"],
+	"properties": {
+		"value": {"type": "integer", "minimum": 0, "maximum": 100},
+		"name": {"type": "string", "pattern": "^node-[0-9]+$"},
+		"peers": {"type": "array", "items": {"type": "integer"}, "uniqueItems": true},
+		"role": {"enum": ["leader", "follower"]}
+	},
+	"additionalProperties": false
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := map[string]any{"value": 3., "name": "node-1", "peers": []any{1., 2.}, "role": "leader"}
+	if err := Validate(s, valid); err != nil {
+		t.Errorf("Expected valid data, got %v", err)
+	}
+
+	// not normalized data is accepted as well
+	if err := Validate(s, struct{ Value int }{3}); err == nil {
+		t.Error("Field names are case sensitive, value should be missing")
+	}
+
+	invalid := map[string]map[string]any{
+		"/":        {"name": "node-1"},
+		"/value":   {"value": 101.},
+		"/name":    {"value": 1., "name": "n1"},
+		"/peers/1": {"value": 1., "peers": []any{1., "2"}},
+		"/role":    {"value": 1., "role": "observer"},
+		"/other":   {"value": 1., "other": true},
+	}
+	for path, data := range invalid {
+		err := Validate(s, data)
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("Expected a validation error for %v, got %v", data, err)
+			continue
+		}
+		if verr.Path != path && !(path == "/" && verr.Path == "") {
+			t.Errorf("Expected the error at %s, got %v", path, verr)
+		}
+	}
+}
+
+func TestParse_Invalid_Schema(t *testing.T) {
+	for _, s := range []string{`{"type": "float"}`, `{"type": ["null", "float"]}`, `{"minimum": "1"}`, `{"properties": {"a": 1}}`, `[`} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Expected %s to be rejected", s)
+		}
+	}
+}
+
+func TestParse_Reports_All_Invalid_Keywords(t *testing.T) {
+	_, err := Parse(`{
+		"type": "float",
+		"minimum": "1",
+		"properties": {"a": {"pattern": 1}, "b": {"items": {"enum": 1}}},
+		"anyOf": [{"maxLength": true}]
+	}`)
+	if err == nil {
+		t.Fatal("Expected the schema to be rejected")
+	}
+
+	for _, reason := range []string{
+		`unknown type "float" at ""`,
+		`minimum at "" has to be a number`,
+		`pattern at "/a" has to be a string`,
+		`enum at "/b/items" has to be a list`,
+		`maxLength at "" has to be a number`,
+	} {
+		if !strings.Contains(err.Error(), reason) {
+			t.Errorf("Expected %q to be reported, got %v", reason, err)
+		}
+	}
+}
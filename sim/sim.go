@@ -0,0 +1,155 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+/* Typed access to what the emulator provides to the users code through the
+* context of Run, instead of string keys and type assertions :
+*
+*   import "distributed-sys-emulator/sim"
+*
+*   func Run(ctx context.Context, fSend func(int, any) int, fAwait func(int) []any) any {
+*       sim.Broadcast(ctx, sim.ID(ctx))
+*       for _, msg := range sim.Await(ctx, len(sim.InNeighbors(ctx))) {
+*           fmt.Println(msg.From, msg.Data)
+*       }
+*       ...
+*
+* The accessors panic if ctx is not (derived from) the context passed to Run.
+* The package has no dependencies, so it can be copied into exported modules.
+ */
+
+// a received message
+type Message struct {
+	From int
+	To   int
+	Data any
+}
+
+func value[T any](ctx context.Context, key string) T {
+	v, ok := ctx.Value(key).(T)
+	if !ok {
+		panic(fmt.Sprintf("sim : %q is missing, ctx has to be derived from the context passed to Run", key))
+	}
+	return v
+}
+
+// the id of this node
+func ID(ctx context.Context) int {
+	return value[int](ctx, "id")
+}
+
+// the nodes (ids) that this node can currently send to
+func OutNeighbors(ctx context.Context) []int {
+	if neighbors, ok := ctx.Value("neighbors").(func() ([]int, []int)); ok {
+		out, _ := neighbors()
+		return out
+	}
+	return value[[]int](ctx, "out-neighbors")
+}
+
+// the nodes (ids) that this node can currently receive from
+func InNeighbors(ctx context.Context) []int {
+	if neighbors, ok := ctx.Value("neighbors").(func() ([]int, []int)); ok {
+		_, in := neighbors()
+		return in
+	}
+	return value[[]int](ctx, "in-neighbors")
+}
+
+// registers f to be called with the current neighbors whenever connections of
+// this node are added or removed while it runs. Does nothing where the
+// topology is fixed e.g. in exported modules.
+func OnTopologyChange(ctx context.Context, f func(out, in []int)) {
+	if onChange, ok := ctx.Value("on-topology-change").(func(func([]int, []int))); ok {
+		onChange(f)
+	}
+}
+
+// the custom data of this node as it was configured
+func CustomValue(ctx context.Context) any {
+	return ctx.Value("custom")
+}
+
+// decodes the custom data into v like json.Unmarshal
+func CustomInto(ctx context.Context, v any) error {
+	b, err := json.Marshal(CustomValue(ctx))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// the custom data as T, decoded like json.Unmarshal if it is not a T already
+func Custom[T any](ctx context.Context) (T, error) {
+	if v, ok := CustomValue(ctx).(T); ok {
+		return v, nil
+	}
+	var v T
+	err := CustomInto(ctx, &v)
+	return v, err
+}
+
+// sends data to a node, returns the number of nodes reached (0 or 1)
+func Send(ctx context.Context, to int, data any) int {
+	fSend := value[func(int, any) int](ctx, "send")
+	return fSend(to, data)
+}
+
+// sends data to every out-neighbor, returns the number of nodes reached
+func Broadcast(ctx context.Context, data any) int {
+	fSend := value[func(int, any) int](ctx, "send")
+	reached := 0
+	for _, to := range OutNeighbors(ctx) {
+		reached += fSend(to, data)
+	}
+	return reached
+}
+
+// waits for cnt messages from any of the in-neighbors, returns less once ctx
+// is done
+func Await(ctx context.Context, cnt int) []Message {
+	fAwait := value[func(int) []any](ctx, "await")
+	received := fAwait(cnt)
+	res := make([]Message, 0, len(received))
+	for _, r := range received {
+		if msg, ok := toMessage(r); ok {
+			res = append(res, msg)
+		}
+	}
+	return res
+}
+
+// received messages are structs with From, To and Data fields, whose type
+// depends on where the code runs
+func toMessage(r any) (Message, bool) {
+	v := reflect.ValueOf(r)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return Message{}, false
+	}
+
+	from, to, data := v.FieldByName("From"), v.FieldByName("To"), v.FieldByName("Data")
+	if !from.CanInt() || !to.CanInt() || !data.IsValid() || !data.CanInterface() {
+		return Message{}, false
+	}
+	return Message{From: int(from.Int()), To: int(to.Int()), Data: data.Interface()}, true
+}
+
+// sends a request to a node and waits for the response of its handler
+func Call(ctx context.Context, to int, request any) (any, error) {
+	fCall := value[func(int, any) (any, error)](ctx, "call")
+	return fCall(to, request)
+}
+
+// registers the function answering the calls of other nodes
+func Handle(ctx context.Context, handler func(from int, request any) any) {
+	fHandle := value[func(func(int, any) any)](ctx, "handle")
+	fHandle(handler)
+}
@@ -0,0 +1,74 @@
+package sim
+
+import (
+	"context"
+	"distributed-sys-emulator/bus"
+	"testing"
+)
+
+type testConfig struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+func testContext() (context.Context, *[]int) {
+	var sentTo []int
+	fSend := func(to int, data any) int {
+		sentTo = append(sentTo, to)
+		return 1
+	}
+	fAwait := func(cnt int) []any {
+		return []any{bus.SendTask{From: 2, To: 1, Data: "data"}}
+	}
+
+	ctx := context.WithValue(context.Background(), "id", 1)
+	ctx = context.WithValue(ctx, "out-neighbors", []int{0, 2})
+	ctx = context.WithValue(ctx, "in-neighbors", []int{2})
+	ctx = context.WithValue(ctx, "custom", map[string]any{"name": "a", "weight": 3.0})
+	ctx = context.WithValue(ctx, "send", fSend)
+	ctx = context.WithValue(ctx, "await", fAwait)
+	return ctx, &sentTo
+}
+
+func TestSim_Accessors(t *testing.T) {
+	ctx, _ := testContext()
+
+	if ID(ctx) != 1 {
+		t.Errorf("Expected id 1, got %d", ID(ctx))
+	}
+	if len(OutNeighbors(ctx)) != 2 || len(InNeighbors(ctx)) != 1 {
+		t.Error("Neighbors don't match")
+	}
+
+	c, err := Custom[testConfig](ctx)
+	if err != nil || c.Name != "a" || c.Weight != 3 {
+		t.Errorf("Custom data was not decoded, got %v, %v", c, err)
+	}
+
+	var into testConfig
+	if err := CustomInto(ctx, &into); err != nil || into != c {
+		t.Errorf("CustomInto doesn't match Custom, got %v, %v", into, err)
+	}
+}
+
+func TestSim_Broadcast_Await(t *testing.T) {
+	ctx, sentTo := testContext()
+
+	if reached := Broadcast(ctx, "data"); reached != 2 || len(*sentTo) != 2 {
+		t.Errorf("Expected to reach both out-neighbors, reached %d", reached)
+	}
+
+	msgs := Await(ctx, 1)
+	if len(msgs) != 1 || msgs[0].From != 2 || msgs[0].Data != "data" {
+		t.Errorf("Unexpected messages %v", msgs)
+	}
+}
+
+func TestSim_Missing_Context_Value(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected a panic for a context without node values")
+		}
+	}()
+	ID(context.Background())
+}
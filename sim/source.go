@@ -0,0 +1,13 @@
+package sim
+
+import "embed"
+
+// Source of the package, to be copied into exported modules
+//
+//go:embed sim.go
+var Source []byte
+
+// Files holds the source of the package, to be interpreted
+//
+//go:embed sim.go
+var Files embed.FS
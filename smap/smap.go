@@ -12,6 +12,7 @@ type SMap[K comparable, V any] interface {
 	Load(key K) (V, bool)
 	Update(key K, modifier func(value V) (V, bool)) (V, bool)
 	Delete(key K)
+	Keys() []K
 }
 
 type smap[K comparable, V any] struct {
@@ -37,6 +38,16 @@ func (s *smap[K, V]) Delete(key K) {
 	delete(s.m, key)
 }
 
+func (s *smap[K, V]) Keys() []K {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]K, 0, len(s.m))
+	for k := range s.m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
 // Define a modifier function to update the value under K
 func (s *smap[K, V]) Update(key K, modifier func(value V) (V, bool)) (V, bool) {
 	s.mu.Lock()